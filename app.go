@@ -7,6 +7,11 @@ type App struct {
 	ThreadManager    *ThreadManager
 	RunManager       *RunManager
 	ThreadRunner     *ThreadRunner
+	UsageManager     *UsageManager
+	BudgetManager    *BudgetManager
+	Chat             *Chat
+	FileManager      *FileManager
+	PromptManager    *PromptManager
 	// Migrate          *migrate.Migrate
 }
 
@@ -23,6 +28,21 @@ func (a *App) Run() error {
 		case args.Assistant.Create != nil:
 			cmd := args.Assistant.Create
 			return am.Create(cmd.AssistantFile)
+		case args.Assistant.Apply != nil:
+			cmd := args.Assistant.Apply
+			return am.Apply(cmd.File)
+		case args.Assistant.Diff != nil:
+			cmd := args.Assistant.Diff
+			return am.Diff(cmd.File, cmd.ID)
+		case args.Assistant.Export != nil:
+			cmd := args.Assistant.Export
+			return am.Export(cmd.ID)
+		case args.Assistant.Update != nil:
+			return am.Update(*args.Assistant.Update)
+		case args.Assistant.Delete != nil:
+			return am.Delete(*args.Assistant.Delete)
+		case args.Assistant.Clone != nil:
+			return am.Clone(*args.Assistant.Clone)
 		case args.Assistant.List != nil:
 			return am.List()
 		case args.Assistant.Use != nil:
@@ -46,11 +66,18 @@ func (a *App) Run() error {
 		case args.Thread.Use != nil:
 			cmd := args.Thread.Use
 			return a.ThreadManager.Use(cmd.ID)
+		case args.Thread.Fork != nil:
+			cmd := args.Thread.Fork
+			return a.ThreadManager.Fork(cmd.At)
 		}
 	case args.Send != nil:
 		cmd := *args.Send
 		// return a.ThreadRunner.RunStream(cmd)
 		return a.ThreadRunner.RunStream(cmd)
+	case args.Retry != nil:
+		return a.ThreadRunner.Retry(*args.Retry)
+	case args.Chat != nil:
+		return a.Chat.Run(*args.Chat)
 	case args.Run != nil:
 		switch {
 		case args.Run.Show != nil:
@@ -60,6 +87,36 @@ func (a *App) Run() error {
 		default:
 			return a.RunManager.Show()
 		}
+	case args.Usage != nil:
+		return a.UsageManager.Report(*args.Usage)
+	case args.Files != nil:
+		switch {
+		case args.Files.GC != nil:
+			return a.FileManager.GC(*args.Files.GC)
+		}
+	case args.Tokens != nil:
+		return a.ThreadRunner.Tokens(*args.Tokens)
+	case args.Prompt != nil:
+		pm := a.PromptManager
+		switch {
+		case args.Prompt.Save != nil:
+			return pm.Save(*args.Prompt.Save)
+		case args.Prompt.Show != nil:
+			return pm.Show(args.Prompt.Show.Name)
+		case args.Prompt.Remove != nil:
+			return pm.Remove(args.Prompt.Remove.Name)
+		case args.Prompt.Run != nil:
+			return pm.Run(*args.Prompt.Run)
+		default:
+			return pm.List()
+		}
+	case args.Budget != nil:
+		switch {
+		case args.Budget.Set != nil:
+			return a.BudgetManager.Set(*args.Budget.Set)
+		default:
+			return a.BudgetManager.Show()
+		}
 	}
 
 	return nil
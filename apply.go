@@ -0,0 +1,597 @@
+package gpt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hayeah/goo"
+	"github.com/hayeah/goo/fetch"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// assistantKeyMetadata is the metadata key that identifies an applied
+	// assistant. If a definition doesn't set it, its name is used.
+	assistantKeyMetadata = "gpt_key"
+	// assistantHashMetadata is the metadata key of the definition's hash.
+	assistantHashMetadata = "__hash__"
+)
+
+// assistantFields are the fields of an assistant that a definition can set,
+// in the order they are exported.
+var assistantFields = []string{
+	"name",
+	"description",
+	"model",
+	"instructions",
+	"tools",
+	"tool_resources",
+	"temperature",
+	"top_p",
+	"response_format",
+	"metadata",
+}
+
+// assistantStringFields are the fields of an assistant that are strings.
+var assistantStringFields = []string{"name", "description", "model", "instructions"}
+
+// assistantObjectFields are the fields of an assistant that are objects, whose
+// keys can be set one by one.
+var assistantObjectFields = []string{"tool_resources", "metadata"}
+
+// assistantDiffFields are always diffed, even if a definition doesn't set
+// them.
+var assistantDiffFields = []string{"model", "instructions", "tools", "metadata"}
+
+// assistantResetValues are the values that clear the fields of an assistant,
+// or set them back to their defaults. model is required, so it has none.
+var assistantResetValues = map[string]any{
+	"name":         "",
+	"description":  "",
+	"instructions": "",
+	"tools":        []any{},
+	"tool_resources": map[string]any{
+		"code_interpreter": map[string]any{"file_ids": []any{}},
+		"file_search":      map[string]any{"vector_store_ids": []any{}},
+	},
+	"temperature":     1,
+	"top_p":           1,
+	"response_format": "auto",
+	"metadata":        map[string]any{},
+}
+
+// AssistantApply records which assistant a definition was applied to.
+type AssistantApply struct {
+	Key         string `db:"key"`
+	AssistantID string `db:"assistant_id"`
+	Hash        string `db:"hash"`
+	File        string `db:"file"`
+}
+
+// LoadAssistantDefinition reads an assistant definition (a create assistant
+// request) from a file.
+func LoadAssistantDefinition(file string) (map[string]any, error) {
+	var def map[string]any
+	err := goo.DecodeURL(file, &def)
+	if err != nil {
+		return nil, err
+	}
+
+	if def == nil {
+		return nil, fmt.Errorf("%s: empty assistant definition", file)
+	}
+
+	return def, nil
+}
+
+// assistantKey returns the key that identifies the assistant of a definition.
+func assistantKey(def map[string]any) (string, error) {
+	if metadata, ok := def["metadata"].(map[string]any); ok {
+		if key, ok := metadata[assistantKeyMetadata].(string); ok && key != "" {
+			return key, nil
+		}
+	}
+
+	if name, ok := def["name"].(string); ok && name != "" {
+		return name, nil
+	}
+
+	return "", fmt.Errorf("assistant definition needs a name, or a metadata.%s", assistantKeyMetadata)
+}
+
+// hashAssistantRequest hashes an assistant definition, ignoring the hash in
+// its metadata.
+func hashAssistantRequest(def map[string]any) (string, error) {
+	canonical := make(map[string]any, len(def))
+	for k, v := range def {
+		canonical[k] = v
+	}
+
+	if metadata, ok := def["metadata"].(map[string]any); ok {
+		m := make(map[string]any, len(metadata))
+		for k, v := range metadata {
+			if k != assistantHashMetadata {
+				m[k] = v
+			}
+		}
+		canonical["metadata"] = m
+	}
+
+	// json.Marshal sorts map keys, so equal definitions hash equally
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Apply creates the assistant of a definition, or updates it if it was
+// created before and the definition changed.
+func (am *AssistantManager) Apply(file string) error {
+	def, err := LoadAssistantDefinition(file)
+	if err != nil {
+		return err
+	}
+
+	key, err := assistantKey(def)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	hash, err := hashAssistantRequest(def)
+	if err != nil {
+		return err
+	}
+
+	metadata, _ := def["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata[assistantKeyMetadata] = key
+	metadata[assistantHashMetadata] = hash
+	def["metadata"] = metadata
+
+	remote, err := am.findApplied(key)
+	if err != nil {
+		return err
+	}
+
+	var r gjson.Result
+	switch {
+	case !remote.Exists():
+		// https://platform.openai.com/docs/api-reference/assistants/createAssistant
+		// POST https://api.openai.com/v1/assistants
+		created, err := am.oai.JSON("POST", "/assistants", &fetch.Options{
+			Body: def,
+		})
+		if err != nil {
+			return err
+		}
+
+		r = created.Result
+
+		fmt.Printf("created %s (%s)\n", r.Get("id"), key)
+	case remote.Get("metadata."+assistantHashMetadata).String() == hash:
+		r = remote
+		fmt.Printf("unchanged %s (%s)\n", r.Get("id"), key)
+	default:
+		// https://platform.openai.com/docs/api-reference/assistants/modifyAssistant
+		// POST https://api.openai.com/v1/assistants/{assistant_id}
+		updated, err := am.oai.JSON("POST", "/assistants/{{.}}", &fetch.Options{
+			PathParams: remote.Get("id").String(),
+			Body:       withResetFields(def),
+		})
+		if err != nil {
+			return err
+		}
+
+		r = updated.Result
+
+		fmt.Printf("updated %s (%s)\n", r.Get("id"), key)
+	}
+
+	_, err = am.db.NamedExec(`
+		INSERT INTO assistant_applies (key, assistant_id, hash, file) VALUES (:key, :assistant_id, :hash, :file)
+		ON CONFLICT(key) DO UPDATE SET
+			assistant_id = excluded.assistant_id, hash = excluded.hash, file = excluded.file,
+			applied_at = CURRENT_TIMESTAMP
+	`, &AssistantApply{Key: key, AssistantID: r.Get("id").String(), Hash: hash, File: file})
+	if err != nil {
+		return fmt.Errorf("record apply: %w", err)
+	}
+
+	return nil
+}
+
+// withResetFields returns a copy of a definition that resets the fields it
+// doesn't set. Modifying an assistant only changes the fields in the request,
+// so a field removed from a definition would stay on the assistant otherwise.
+func withResetFields(def map[string]any) map[string]any {
+	body := make(map[string]any, len(assistantFields))
+	for field, v := range assistantResetValues {
+		body[field] = v
+	}
+
+	for field, v := range def {
+		body[field] = v
+	}
+
+	return body
+}
+
+// findApplied finds the assistant a definition was applied to: the one
+// recorded locally if it still exists, otherwise the remote assistant with
+// the key in its metadata, or with the key as its name. Returns an empty
+// result if there is none.
+func (am *AssistantManager) findApplied(key string) (gjson.Result, error) {
+	var assistantID string
+	err := am.db.Get(&assistantID, "SELECT assistant_id FROM assistant_applies WHERE key = ?", key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return gjson.Result{}, err
+	}
+
+	if assistantID != "" {
+		// https://platform.openai.com/docs/api-reference/assistants/getAssistant
+		// GET https://api.openai.com/v1/assistants/{assistant_id}
+		r, err := am.oai.doJSON("GET", "/assistants/"+assistantID, nil)
+		if err == nil {
+			return r, nil
+		}
+
+		if !isNotFound(err) {
+			return gjson.Result{}, err
+		}
+
+		// deleted remotely, fall back to searching
+	}
+
+	assistants, err := am.listAssistants()
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	return matchApplied(assistants, key)
+}
+
+// matchApplied picks the assistant with the key in its metadata, or else the
+// one with the key as its name. It's an error if several match, e.g. after an
+// exported definition was created again rather than applied.
+func matchApplied(assistants []gjson.Result, key string) (gjson.Result, error) {
+	var byKey, byName []gjson.Result
+	for _, a := range assistants {
+		switch {
+		case a.Get("metadata."+assistantKeyMetadata).String() == key:
+			byKey = append(byKey, a)
+		case !a.Get("metadata."+assistantKeyMetadata).Exists() && a.Get("name").String() == key:
+			byName = append(byName, a)
+		}
+	}
+
+	switch {
+	case len(byKey) > 1:
+		return gjson.Result{}, fmt.Errorf("%d assistants have metadata.%s %q (%s), delete all but one", len(byKey), assistantKeyMetadata, key, assistantIDs(byKey))
+	case len(byKey) == 1:
+		return byKey[0], nil
+	case len(byName) > 1:
+		return gjson.Result{}, fmt.Errorf("%d assistants are named %q (%s), set metadata.%s to pick one", len(byName), key, assistantIDs(byName), assistantKeyMetadata)
+	case len(byName) == 1:
+		return byName[0], nil
+	default:
+		return gjson.Result{}, nil
+	}
+}
+
+func assistantIDs(assistants []gjson.Result) string {
+	var ids []string
+	for _, a := range assistants {
+		ids = append(ids, a.Get("id").String())
+	}
+
+	return strings.Join(ids, ", ")
+}
+
+// listAssistants lists all assistants.
+func (am *AssistantManager) listAssistants() ([]gjson.Result, error) {
+	var assistants []gjson.Result
+	var after string
+
+	for {
+		query := "limit=100"
+		if after != "" {
+			query += "&after={{after}}"
+		}
+
+		// https://platform.openai.com/docs/api-reference/assistants/listAssistants
+		// GET https://api.openai.com/v1/assistants
+		r, err := am.oai.JSON("GET", "/assistants?"+query, &fetch.Options{
+			PathParams: map[string]string{"after": after},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		page := r.Get("data").Array()
+		assistants = append(assistants, page...)
+
+		if !r.Get("has_more").Bool() || len(page) == 0 {
+			return assistants, nil
+		}
+
+		after = page[len(page)-1].Get("id").String()
+	}
+}
+
+// Diff prints the differences between a definition and the remote assistant,
+// field by field. The assistant is the one the definition was applied to,
+// unless an id is given.
+func (am *AssistantManager) Diff(file, assistantID string) error {
+	def, err := LoadAssistantDefinition(file)
+	if err != nil {
+		return err
+	}
+
+	var remote gjson.Result
+	if assistantID != "" {
+		// https://platform.openai.com/docs/api-reference/assistants/getAssistant
+		// GET https://api.openai.com/v1/assistants/{assistant_id}
+		r, err := am.oai.JSON("GET", "/assistants/{{.}}", &fetch.Options{
+			PathParams: assistantID,
+		})
+		if err != nil {
+			return err
+		}
+
+		remote = r.Result
+	} else {
+		key, err := assistantKey(def)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		remote, err = am.findApplied(key)
+		if err != nil {
+			return err
+		}
+
+		if !remote.Exists() {
+			return fmt.Errorf("no assistant found for %q, pass an assistant id", key)
+		}
+	}
+
+	diff, err := diffAssistant(def, remote, file)
+	if err != nil {
+		return err
+	}
+
+	if diff == "" {
+		fmt.Printf("%s is up to date with %s\n", file, remote.Get("id"))
+		return nil
+	}
+
+	fmt.Print(diff)
+	return nil
+}
+
+// diffAssistant returns a unified diff of each field that differs between a
+// definition and a remote assistant. Fields the definition doesn't set are
+// skipped, except for assistantDiffFields.
+func diffAssistant(def map[string]any, remote gjson.Result, file string) (string, error) {
+	var b strings.Builder
+	for _, field := range assistantFields {
+		local, ok := def[field]
+		if !ok && !slices.Contains(assistantDiffFields, field) {
+			continue
+		}
+
+		if field == "metadata" {
+			local = withoutAppliedMetadata(local)
+		}
+
+		var remoteValue any
+		if r := remote.Get(field); r.Exists() {
+			err := json.Unmarshal([]byte(r.Raw), &remoteValue)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if field == "metadata" {
+			remoteValue = withoutAppliedMetadata(remoteValue)
+		}
+
+		remoteText, err := diffText(remoteValue)
+		if err != nil {
+			return "", err
+		}
+
+		localText, err := diffText(local)
+		if err != nil {
+			return "", err
+		}
+
+		if remoteText == localText {
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(remoteText),
+			B:        difflib.SplitLines(localText),
+			FromFile: remote.Get("id").String() + " " + field,
+			ToFile:   file + " " + field,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(diff)
+	}
+
+	return b.String(), nil
+}
+
+// diffText formats a field value for diffing. Strings are diffed as is, so
+// that instructions diff line by line. Empty values are all the same.
+func diffText(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if v == "" {
+			return "", nil
+		}
+		return strings.TrimSuffix(v, "\n") + "\n", nil
+	case []any:
+		if len(v) == 0 {
+			return "", nil
+		}
+	case map[string]any:
+		if len(v) == 0 {
+			return "", nil
+		}
+	}
+
+	data, err := marshalIndent(v, "")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data) + "\n", nil
+}
+
+// marshalIndent is like json.MarshalIndent, but doesn't escape <, > and &,
+// which instructions and metadata often have.
+func marshalIndent(v any, prefix string) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(prefix, "  ")
+
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// withoutAppliedMetadata removes the metadata that Apply adds.
+func withoutAppliedMetadata(v any) any {
+	metadata, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+
+	m := make(map[string]any, len(metadata))
+	for k, v := range metadata {
+		if k != assistantKeyMetadata && k != assistantHashMetadata {
+			m[k] = v
+		}
+	}
+
+	return m
+}
+
+// Export prints an assistant as a definition that Create and Apply accept.
+func (am *AssistantManager) Export(assistantID string) error {
+	var err error
+	if assistantID == "" {
+		assistantID, err = am.CurrentAssistantID()
+		if err != nil {
+			return err
+		}
+	}
+
+	// https://platform.openai.com/docs/api-reference/assistants/getAssistant
+	// GET https://api.openai.com/v1/assistants/{assistant_id}
+	r, err := am.oai.JSON("GET", "/assistants/{{.}}", &fetch.Options{
+		PathParams: assistantID,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := exportAssistant(r.Result)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// assistantDefinition converts an assistant object to a definition, keeping
+// the fields a definition can set.
+func assistantDefinition(assistant gjson.Result) (map[string]any, error) {
+	def := map[string]any{}
+	for _, field := range assistantFields {
+		v := assistant.Get(field)
+		if !v.Exists() || v.Type == gjson.Null {
+			continue
+		}
+
+		var value any
+		err := json.Unmarshal([]byte(v.Raw), &value)
+		if err != nil {
+			return nil, err
+		}
+
+		def[field] = value
+	}
+
+	if metadata, ok := def["metadata"].(map[string]any); ok {
+		// keep the key, so that apply finds the assistant again
+		delete(metadata, assistantHashMetadata)
+		if len(metadata) == 0 {
+			delete(def, "metadata")
+		}
+	}
+
+	return def, nil
+}
+
+// exportAssistant formats an assistant object as a definition, with the
+// fields in a stable order.
+func exportAssistant(assistant gjson.Result) ([]byte, error) {
+	def, err := assistantDefinition(assistant)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("{\n")
+
+	first := true
+	for _, field := range assistantFields {
+		value, ok := def[field]
+		if !ok {
+			continue
+		}
+
+		data, err := marshalIndent(value, "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		if !first {
+			b.WriteString(",\n")
+		}
+		first = false
+
+		fmt.Fprintf(&b, "  %q: %s", field, data)
+	}
+
+	b.WriteString("\n}\n")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,222 @@
+package gpt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestAssistantKey(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := assistantKey(map[string]any{"name": "reviewer"})
+	assert.NoError(err)
+	assert.Equal("reviewer", key)
+
+	key, err = assistantKey(map[string]any{
+		"name":     "reviewer",
+		"metadata": map[string]any{"gpt_key": "team/reviewer"},
+	})
+	assert.NoError(err)
+	assert.Equal("team/reviewer", key)
+
+	_, err = assistantKey(map[string]any{"model": "gpt-4o"})
+	assert.Error(err)
+}
+
+func TestHashAssistantRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	def := map[string]any{
+		"name":     "reviewer",
+		"model":    "gpt-4o",
+		"metadata": map[string]any{"author": "me"},
+	}
+
+	hash, err := hashAssistantRequest(def)
+	assert.NoError(err)
+	assert.Len(hash, 64)
+
+	// the stored hash doesn't change the hash
+	hashed := map[string]any{
+		"model":    "gpt-4o",
+		"name":     "reviewer",
+		"metadata": map[string]any{"author": "me", "__hash__": hash},
+	}
+	rehash, err := hashAssistantRequest(hashed)
+	assert.NoError(err)
+	assert.Equal(hash, rehash)
+
+	def["model"] = "gpt-4o-mini"
+	changed, err := hashAssistantRequest(def)
+	assert.NoError(err)
+	assert.NotEqual(hash, changed)
+}
+
+func TestWithResetFields(t *testing.T) {
+	assert := assert.New(t)
+
+	def := map[string]any{"name": "reviewer", "model": "gpt-4o"}
+	body := withResetFields(def)
+
+	assert.Equal("reviewer", body["name"])
+	assert.Equal("gpt-4o", body["model"])
+	assert.Equal("", body["instructions"])
+	assert.Equal([]any{}, body["tools"])
+
+	// every field is sent, reset if the definition does not set it
+	for _, field := range assistantFields {
+		assert.Contains(body, field)
+	}
+
+	// the definition is not modified
+	assert.Len(def, 2)
+}
+
+func TestMatchApplied(t *testing.T) {
+	assert := assert.New(t)
+
+	assistants := gjson.Parse(`[
+		{"id": "asst_1", "name": "reviewer", "metadata": {}},
+		{"id": "asst_2", "name": "reviewer", "metadata": {"gpt_key": "team/reviewer"}},
+		{"id": "asst_3", "name": "writer", "metadata": {"gpt_key": "writer"}},
+		{"id": "asst_4", "name": "writer copy", "metadata": {"gpt_key": "writer"}},
+		{"id": "asst_5", "name": "editor", "metadata": {}},
+		{"id": "asst_6", "name": "editor", "metadata": {}}
+	]`).Array()
+
+	r, err := matchApplied(assistants, "team/reviewer")
+	assert.NoError(err)
+	assert.Equal("asst_2", r.Get("id").String())
+
+	// assistants with another key don't match by name
+	r, err = matchApplied(assistants, "reviewer")
+	assert.NoError(err)
+	assert.Equal("asst_1", r.Get("id").String())
+
+	_, err = matchApplied(assistants, "writer")
+	assert.ErrorContains(err, "2 assistants have metadata.gpt_key \"writer\" (asst_3, asst_4)")
+
+	_, err = matchApplied(assistants, "editor")
+	assert.ErrorContains(err, "2 assistants are named \"editor\"")
+
+	r, err = matchApplied(assistants, "translator")
+	assert.NoError(err)
+	assert.False(r.Exists())
+}
+
+func TestDiffAssistant(t *testing.T) {
+	assert := assert.New(t)
+
+	remote := gjson.Parse(`{
+		"id": "asst_1",
+		"name": "reviewer",
+		"model": "gpt-4o-mini",
+		"instructions": "Review code.\nBe brief.",
+		"tools": [],
+		"temperature": 1,
+		"metadata": {"author": "me", "gpt_key": "reviewer", "__hash__": "abc"}
+	}`)
+
+	diff, err := diffAssistant(map[string]any{
+		"name":         "reviewer",
+		"model":        "gpt-4o-mini",
+		"instructions": "Review code.\nBe brief.\n",
+		"metadata":     map[string]any{"author": "me"},
+	}, remote, "reviewer.jsonc")
+	assert.NoError(err)
+	assert.Empty(diff)
+
+	diff, err = diffAssistant(map[string]any{
+		"name":         "reviewer",
+		"model":        "gpt-4o",
+		"instructions": "Review code.\nBe thorough.",
+	}, remote, "reviewer.jsonc")
+	assert.NoError(err)
+	assert.Contains(diff, "--- asst_1 model\n+++ reviewer.jsonc model\n")
+	assert.Contains(diff, "-gpt-4o-mini\n+gpt-4o\n")
+	assert.Contains(diff, " Review code.\n-Be brief.\n+Be thorough.\n")
+	assert.Contains(diff, "-  \"author\": \"me\"\n")
+	assert.NotContains(diff, "temperature")
+}
+
+func TestExportAssistant(t *testing.T) {
+	assert := assert.New(t)
+
+	data, err := exportAssistant(gjson.Parse(`{
+		"id": "asst_1",
+		"object": "assistant",
+		"created_at": 1700000000,
+		"name": "reviewer",
+		"description": null,
+		"model": "gpt-4o",
+		"instructions": "Review <code> & tests.",
+		"tools": [{"type": "file_search"}],
+		"metadata": {"gpt_key": "reviewer", "__hash__": "abc"}
+	}`))
+	assert.NoError(err)
+	assert.Equal(`{
+  "name": "reviewer",
+  "model": "gpt-4o",
+  "instructions": "Review <code> & tests.",
+  "tools": [
+    {
+      "type": "file_search"
+    }
+  ],
+  "metadata": {
+    "gpt_key": "reviewer"
+  }
+}
+`, string(data))
+}
+
+func TestEditAssistant(t *testing.T) {
+	assert := assert.New(t)
+
+	def := map[string]any{
+		"name":     "reviewer",
+		"model":    "gpt-4o-mini",
+		"metadata": map[string]any{"author": "me"},
+	}
+
+	err := editAssistant(def, AssistantEditArgs{
+		Set: []string{
+			"model=gpt-4o",
+			"temperature=0.2",
+			"tools=[{\"type\":\"file_search\"}]",
+			"metadata.version=2",
+		},
+		InstructionsFile: "testdata/prompts/preamble.md",
+	})
+	assert.NoError(err)
+
+	assert.Equal("gpt-4o", def["model"])
+	assert.Equal(0.2, def["temperature"])
+	assert.Equal([]any{map[string]any{"type": "file_search"}}, def["tools"])
+	assert.Equal(map[string]any{"author": "me", "version": "2"}, def["metadata"])
+	assert.NotEmpty(def["instructions"])
+
+	// string fields are never parsed as JSON
+	err = editAssistant(def, AssistantEditArgs{Set: []string{"name=2024"}})
+	assert.NoError(err)
+	assert.Equal("2024", def["name"])
+
+	err = editAssistant(def, AssistantEditArgs{Set: []string{"tool_resources.file_search.vector_store_ids=[\"vs_1\"]"}})
+	assert.NoError(err)
+	assert.Equal(map[string]any{"file_search": map[string]any{"vector_store_ids": []any{"vs_1"}}}, def["tool_resources"])
+
+	// only objects have nested fields
+	err = editAssistant(def, AssistantEditArgs{Set: []string{"tools.0.type=code_interpreter"}})
+	assert.ErrorContains(err, "not an object")
+	err = editAssistant(def, AssistantEditArgs{Set: []string{"model.x=1"}})
+	assert.ErrorContains(err, "not an object")
+	assert.Equal("gpt-4o", def["model"])
+
+	err = editAssistant(def, AssistantEditArgs{Set: []string{"colour=blue"}})
+	assert.ErrorContains(err, "unknown assistant field")
+
+	err = editAssistant(def, AssistantEditArgs{Set: []string{"model"}})
+	assert.ErrorContains(err, "expected key=value")
+}
@@ -1,15 +1,23 @@
 package gpt
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/hayeah/goo"
 	"github.com/hayeah/goo/fetch"
+	"github.com/jmoiron/sqlx"
+	"github.com/tidwall/gjson"
 )
 
 type AssistantManager struct {
 	oai    *OpenAIV2API
 	JSONDB *JSONDB
+	db     *sqlx.DB
 }
 
 // Show retrieves assistant info
@@ -102,23 +110,196 @@ func (am *AssistantManager) CurrentAssistantID() (string, error) {
 	return assistantID, nil
 }
 
-// hashAssistantRequest prepends sha256 to the description
-// func hashAssistantRequest(aReq *openai.AssistantRequest) error {
-// 	aReqJSON, err := json.Marshal(aReq)
-// 	if err != nil {
-// 		return err
-// 	}
+// Update modifies an assistant. The applied hash is dropped from its metadata,
+// so that the next apply of its definition overwrites the changes.
+func (am *AssistantManager) Update(cmd AssistantUpdateCmd) error {
+	if len(cmd.Set) == 0 && cmd.InstructionsFile == "" {
+		return errors.New("nothing to update, pass --set or --instructions-file")
+	}
 
-// 	hashed := sha256.Sum256(aReqJSON)
+	assistant, err := am.get(cmd.ID)
+	if err != nil {
+		return err
+	}
 
-// 	metadata := aReq.Metadata
+	// modify replaces the metadata, so start from the current definition
+	def, err := assistantDefinition(assistant)
+	if err != nil {
+		return err
+	}
 
-// 	if metadata == nil {
-// 		metadata = make(map[string]interface{})
-// 		aReq.Metadata = metadata
-// 	}
+	err = editAssistant(def, cmd.AssistantEditArgs)
+	if err != nil {
+		return err
+	}
 
-// 	metadata["__hash__"] = hex.EncodeToString(hashed[:])
+	// https://platform.openai.com/docs/api-reference/assistants/modifyAssistant
+	// POST https://api.openai.com/v1/assistants/{assistant_id}
+	r, err := am.oai.doJSON("POST", "/assistants/"+cmd.ID, def)
+	if err != nil {
+		return err
+	}
 
-// 	return nil
-// }
+	fmt.Println(r)
+
+	return nil
+}
+
+// Delete deletes an assistant, after asking for confirmation. If it's the
+// current assistant, no assistant is current afterwards.
+func (am *AssistantManager) Delete(cmd AssistantDeleteCmd) error {
+	assistant, err := am.get(cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	if !cmd.Yes {
+		ok, err := confirm(fmt.Sprintf("delete assistant %s (%s)? [y/N] ", cmd.ID, assistant.Get("name")))
+		if err != nil {
+			return fmt.Errorf("%w, pass --yes to delete", err)
+		}
+
+		if !ok {
+			return nil
+		}
+	}
+
+	// https://platform.openai.com/docs/api-reference/assistants/deleteAssistant
+	// DELETE https://api.openai.com/v1/assistants/{assistant_id}
+	_, err = am.oai.doJSON("DELETE", "/assistants/"+cmd.ID, nil)
+	if err != nil {
+		return err
+	}
+
+	var currentID string
+	_, err = am.JSONDB.Get("currentAssistant", &currentID)
+	if err != nil {
+		return err
+	}
+
+	if currentID == cmd.ID {
+		err = am.JSONDB.Delete("currentAssistant")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = am.db.Exec("DELETE FROM assistant_applies WHERE assistant_id = ?", cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("deleted %s\n", cmd.ID)
+
+	return nil
+}
+
+// Clone creates a copy of an assistant, with modifications, and uses it like
+// Create does. The copy is not tied to the definition the original was
+// applied from.
+func (am *AssistantManager) Clone(cmd AssistantCloneCmd) error {
+	var err error
+	assistantID := cmd.ID
+	if assistantID == "" {
+		assistantID, err = am.CurrentAssistantID()
+		if err != nil {
+			return err
+		}
+	}
+
+	assistant, err := am.get(assistantID)
+	if err != nil {
+		return err
+	}
+
+	def, err := assistantDefinition(assistant)
+	if err != nil {
+		return err
+	}
+
+	if metadata, ok := def["metadata"].(map[string]any); ok {
+		delete(metadata, assistantKeyMetadata)
+	}
+
+	if name, ok := def["name"].(string); ok {
+		def["name"] = name + " (copy)"
+	}
+
+	err = editAssistant(def, cmd.AssistantEditArgs)
+	if err != nil {
+		return err
+	}
+
+	// https://platform.openai.com/docs/api-reference/assistants/createAssistant
+	// POST https://api.openai.com/v1/assistants
+	r, err := am.oai.doJSON("POST", "/assistants", def)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(r)
+
+	return am.Use(r.Get("id").String())
+}
+
+func (am *AssistantManager) get(assistantID string) (gjson.Result, error) {
+	// https://platform.openai.com/docs/api-reference/assistants/getAssistant
+	// GET https://api.openai.com/v1/assistants/{assistant_id}
+	return am.oai.doJSON("GET", "/assistants/"+assistantID, nil)
+}
+
+// editAssistant applies --set and --instructions-file to a definition. Keys
+// with dots set the fields of objects, e.g. metadata.author. Values of string
+// fields and of metadata are used as is; others are parsed as JSON if they
+// can be, e.g. temperature=0.2 or tools=[...].
+func editAssistant(def map[string]any, edits AssistantEditArgs) error {
+	for _, pair := range edits.Set {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid --set: %q (expected key=value)", pair)
+		}
+
+		path := strings.Split(key, ".")
+		field := path[0]
+		if !slices.Contains(assistantFields, field) {
+			return fmt.Errorf("invalid --set: unknown assistant field %q", field)
+		}
+
+		if len(path) > 1 && !slices.Contains(assistantObjectFields, field) {
+			return fmt.Errorf("invalid --set: %q is not an object, set it as %s=...", field, field)
+		}
+
+		var v any = value
+		if !slices.Contains(assistantStringFields, field) && field != "metadata" {
+			var parsed any
+			if json.Unmarshal([]byte(value), &parsed) == nil {
+				v = parsed
+			}
+		}
+
+		m := def
+		for _, k := range path[:len(path)-1] {
+			if m[k] == nil {
+				m[k] = map[string]any{}
+			}
+
+			next, ok := m[k].(map[string]any)
+			if !ok {
+				return fmt.Errorf("invalid --set: %q is not an object", k)
+			}
+			m = next
+		}
+		m[path[len(path)-1]] = v
+	}
+
+	if edits.InstructionsFile != "" {
+		data, err := os.ReadFile(edits.InstructionsFile)
+		if err != nil {
+			return fmt.Errorf("read instructions: %w", err)
+		}
+
+		def["instructions"] = string(data)
+	}
+
+	return nil
+}
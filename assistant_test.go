@@ -0,0 +1,103 @@
+package gpt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAssistantManager(t *testing.T, responses map[string]string) (*AssistantManager, *[]apiRequest) {
+	db := newTestDB(t)
+	oai, requests := fakeAPI(t, responses)
+
+	return &AssistantManager{oai: oai, JSONDB: ProvideJSONDB(db), db: db}, requests
+}
+
+func TestAssistantDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	am, requests := newTestAssistantManager(t, map[string]string{
+		"GET /assistants/asst_1":    `{"id": "asst_1", "name": "reviewer"}`,
+		"DELETE /assistants/asst_1": `{"id": "asst_1", "deleted": true}`,
+		"GET /assistants/asst_2":    `{"id": "asst_2", "name": "writer"}`,
+		"DELETE /assistants/asst_2": `{"id": "asst_2", "deleted": true}`,
+	})
+
+	assert.NoError(am.Use("asst_1"))
+	_, err := am.db.Exec(`
+		INSERT INTO assistant_applies (key, assistant_id, hash, file) VALUES
+			('reviewer', 'asst_1', 'abc', 'reviewer.jsonc'),
+			('writer', 'asst_2', 'def', 'writer.jsonc')
+	`)
+	assert.NoError(err)
+
+	// without --yes, confirmation needs a terminal
+	err = am.Delete(AssistantDeleteCmd{ID: "asst_1"})
+	assert.ErrorContains(err, "pass --yes")
+	assert.Empty(requestsTo(*requests, "DELETE"))
+
+	// deleting another assistant keeps the current one
+	assert.NoError(am.Delete(AssistantDeleteCmd{ID: "asst_2", Yes: true}))
+	current, err := am.CurrentAssistantID()
+	assert.NoError(err)
+	assert.Equal("asst_1", current)
+
+	assert.NoError(am.Delete(AssistantDeleteCmd{ID: "asst_1", Yes: true}))
+	assert.Equal([]string{"DELETE /assistants/asst_2", "DELETE /assistants/asst_1"}, requestsTo(*requests, "DELETE"))
+
+	_, err = am.CurrentAssistantID()
+	assert.ErrorContains(err, "no current assistant")
+
+	var applied int
+	assert.NoError(am.db.Get(&applied, "SELECT count(*) FROM assistant_applies"))
+	assert.Equal(0, applied)
+
+	err = am.Delete(AssistantDeleteCmd{ID: "asst_missing", Yes: true})
+	assert.True(isNotFound(err))
+}
+
+func TestAssistantClone(t *testing.T) {
+	assert := assert.New(t)
+
+	am, requests := newTestAssistantManager(t, map[string]string{
+		"GET /assistants/asst_1": `{
+			"id": "asst_1",
+			"object": "assistant",
+			"name": "reviewer",
+			"model": "gpt-4o",
+			"instructions": "Review code.",
+			"tools": [{"type": "file_search"}],
+			"metadata": {"author": "me", "gpt_key": "reviewer", "__hash__": "abc"}
+		}`,
+		"POST /assistants": `{"id": "asst_2"}`,
+	})
+
+	assert.NoError(am.Use("asst_1"))
+
+	err := am.Clone(AssistantCloneCmd{AssistantEditArgs: AssistantEditArgs{Set: []string{"model=gpt-4o-mini"}}})
+	assert.NoError(err)
+
+	var posted []map[string]any
+	for _, r := range *requests {
+		if r.Key == "POST /assistants" {
+			var body map[string]any
+			assert.NoError(json.Unmarshal([]byte(r.Body), &body))
+			posted = append(posted, body)
+		}
+	}
+
+	// the copy is modified, and not tied to the original's definition
+	assert.Equal([]map[string]any{{
+		"name":         "reviewer (copy)",
+		"model":        "gpt-4o-mini",
+		"instructions": "Review code.",
+		"tools":        []any{map[string]any{"type": "file_search"}},
+		"metadata":     map[string]any{"author": "me"},
+	}}, posted)
+
+	// like create, the copy becomes the current assistant
+	current, err := am.CurrentAssistantID()
+	assert.NoError(err)
+	assert.Equal("asst_2", current)
+}
@@ -0,0 +1,188 @@
+package gpt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// ErrBudgetExceeded is returned when a send would exceed a spending budget.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Budget limits daily and monthly spending. Zero values are unlimited.
+type Budget struct {
+	DailyTokens   int64   `json:"daily_tokens,omitempty"`
+	MonthlyTokens int64   `json:"monthly_tokens,omitempty"`
+	DailyUSD      float64 `json:"daily_usd,omitempty"`
+	MonthlyUSD    float64 `json:"monthly_usd,omitempty"`
+
+	// WarnAt is the fraction of a budget at which to start warning. Defaults to 0.8.
+	WarnAt float64 `json:"warn_at,omitempty"`
+}
+
+const keyBudget = "budget"
+
+// BudgetLimit is a single budget limit, and the amount spent against it.
+type BudgetLimit struct {
+	Name  string
+	Limit float64
+	Spent float64
+}
+
+// Fraction returns how much of the limit has been spent.
+func (l BudgetLimit) Fraction() float64 {
+	if l.Limit <= 0 {
+		return 0
+	}
+	return l.Spent / l.Limit
+}
+
+type BudgetManager struct {
+	Usage  *UsageManager
+	cfg    *Config
+	JSONDB *JSONDB
+}
+
+// Budget returns the budget in the config file, or else the one saved in the
+// database. Returns nil if no budget is set.
+func (bm *BudgetManager) Budget() (*Budget, error) {
+	if bm.cfg.Budget != nil {
+		return bm.cfg.Budget, nil
+	}
+
+	var budget Budget
+	ok, err := bm.JSONDB.Get(keyBudget, &budget)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, nil
+	}
+
+	return &budget, nil
+}
+
+// Limits returns the configured limits, with today's and this month's spending.
+func (bm *BudgetManager) Limits() ([]BudgetLimit, error) {
+	budget, err := bm.Budget()
+	if err != nil || budget == nil {
+		return nil, err
+	}
+
+	// runs.created_at is in UTC
+	now := time.Now().UTC()
+
+	today, err := bm.Usage.Total(now.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	month, err := bm.Usage.Total(now.Format("2006-01") + "-01")
+	if err != nil {
+		return nil, err
+	}
+
+	var limits []BudgetLimit
+	add := func(name string, limit float64, spent float64) {
+		if limit > 0 {
+			limits = append(limits, BudgetLimit{Name: name, Limit: limit, Spent: spent})
+		}
+	}
+
+	add("daily tokens", float64(budget.DailyTokens), float64(today.TotalTokens))
+	add("monthly tokens", float64(budget.MonthlyTokens), float64(month.TotalTokens))
+	add("daily USD", budget.DailyUSD, today.Cost)
+	add("monthly USD", budget.MonthlyUSD, month.Cost)
+
+	return limits, nil
+}
+
+// Check returns ErrBudgetExceeded if any budget is used up, and warns on
+// stderr for budgets past the warning threshold.
+func (bm *BudgetManager) Check() error {
+	budget, err := bm.Budget()
+	if err != nil || budget == nil {
+		return err
+	}
+
+	warnAt := budget.WarnAt
+	if warnAt <= 0 {
+		warnAt = 0.8
+	}
+
+	limits, err := bm.Limits()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range limits {
+		if l.Fraction() >= 1 {
+			return fmt.Errorf("%w: %s %.4g of %.4g", ErrBudgetExceeded, l.Name, l.Spent, l.Limit)
+		}
+	}
+
+	for _, l := range limits {
+		if l.Fraction() >= warnAt {
+			fmt.Fprintf(os.Stderr, "warning: %.0f%% of %s budget used (%.4g of %.4g)\n",
+				l.Fraction()*100, l.Name, l.Spent, l.Limit)
+		}
+	}
+
+	return nil
+}
+
+// Show prints the budget limits and spending.
+func (bm *BudgetManager) Show() error {
+	limits, err := bm.Limits()
+	if err != nil {
+		return err
+	}
+
+	if len(limits) == 0 {
+		fmt.Println("no budget set")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BUDGET\tSPENT\tLIMIT\tUSED")
+	for _, l := range limits {
+		fmt.Fprintf(w, "%s\t%.4g\t%.4g\t%.0f%%\n", l.Name, l.Spent, l.Limit, l.Fraction()*100)
+	}
+
+	return w.Flush()
+}
+
+// Set saves the budget in the database. The limits that cmd doesn't give are
+// kept.
+func (bm *BudgetManager) Set(cmd BudgetSetCmd) error {
+	if bm.cfg.Budget != nil {
+		fmt.Fprintln(os.Stderr, "warning: the budget in the config file takes precedence")
+	}
+
+	var budget Budget
+	_, err := bm.JSONDB.Get(keyBudget, &budget)
+	if err != nil {
+		return err
+	}
+
+	if cmd.DailyTokens != nil {
+		budget.DailyTokens = *cmd.DailyTokens
+	}
+	if cmd.MonthlyTokens != nil {
+		budget.MonthlyTokens = *cmd.MonthlyTokens
+	}
+	if cmd.DailyUSD != nil {
+		budget.DailyUSD = *cmd.DailyUSD
+	}
+	if cmd.MonthlyUSD != nil {
+		budget.MonthlyUSD = *cmd.MonthlyUSD
+	}
+	if cmd.WarnAt != nil {
+		budget.WarnAt = *cmd.WarnAt
+	}
+
+	return bm.JSONDB.Put(keyBudget, budget)
+}
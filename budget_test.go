@@ -0,0 +1,67 @@
+package gpt
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	_, err := db.Exec(`
+		INSERT INTO runs (run_id, thread_id, assistant_id, model, status, total_tokens)
+		VALUES ('run_1', 'thread_1', 'asst_1', 'gpt-4o', 'completed', 1000)
+	`)
+	assert.NoError(err)
+
+	cfg := &Config{Budget: &Budget{DailyTokens: 500}}
+	tr := &ThreadRunner{
+		Budget: &BudgetManager{Usage: &UsageManager{db: db, cfg: cfg}, cfg: cfg},
+		log:    slog.Default(),
+	}
+
+	err = tr.checkBudget(false)
+	assert.ErrorIs(err, ErrBudgetExceeded)
+	assert.ErrorContains(err, "use --force")
+
+	assert.NoError(tr.checkBudget(true))
+
+	cfg.Budget.DailyTokens = 2000
+	assert.NoError(tr.checkBudget(false))
+
+	// --force can't bypass other errors, so they don't suggest it
+	db.Close()
+	err = tr.checkBudget(true)
+	assert.Error(err)
+	assert.NotErrorIs(err, ErrBudgetExceeded)
+	assert.NotContains(err.Error(), "--force")
+}
+
+func TestSetBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	bm := &BudgetManager{cfg: &Config{}, JSONDB: ProvideJSONDB(db)}
+
+	dailyUSD := 5.0
+	assert.NoError(bm.Set(BudgetSetCmd{DailyUSD: &dailyUSD}))
+
+	// setting one limit keeps the others
+	monthlyTokens := int64(1_000_000)
+	assert.NoError(bm.Set(BudgetSetCmd{MonthlyTokens: &monthlyTokens}))
+
+	budget, err := bm.Budget()
+	assert.NoError(err)
+	assert.Equal(&Budget{DailyUSD: 5, MonthlyTokens: 1_000_000}, budget)
+
+	// 0 removes a limit
+	dailyUSD = 0
+	assert.NoError(bm.Set(BudgetSetCmd{DailyUSD: &dailyUSD}))
+
+	budget, err = bm.Budget()
+	assert.NoError(err)
+	assert.Equal(&Budget{MonthlyTokens: 1_000_000}, budget)
+}
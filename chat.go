@@ -0,0 +1,376 @@
+package gpt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/chzyer/readline"
+)
+
+const chatHelp = `Type a message and press enter to send. Multi-line messages can be entered
+by ending lines with \, or between lines of """.
+
+  /thread [id|new]     show, switch, or start a new thread
+  /assistant [id]      show or switch the assistant
+  /edit                compose a message in $EDITOR, and send it
+  /file <path>         attach a file's content to the next message
+  /image <url|path>    attach an image to the next message
+  /attach <path>[#tools]
+                       attach a file for file_search or code_interpreter
+  /tools [command]     show or set the command that executes function calls
+  /cancel              cancel the last run, if it's still in progress
+  /help                show this help
+
+Ctrl-C interrupts a streaming reply. Ctrl-D exits.
+`
+
+// Chat is an interactive session that keeps the current assistant and thread
+// between messages.
+type Chat struct {
+	TR  *ThreadRunner
+	AM  *AssistantManager
+	RM  *RunManager
+	cfg *Config
+
+	appDB *AppDB
+}
+
+type chatSession struct {
+	*Chat
+
+	rl      *readline.Instance
+	cmd     ChatCmd
+	opts    *RunOptions
+	pending []json.Marshaler
+
+	// parser parses the inputs of commands and composed messages
+	parser *InputParser
+
+	// newThread is set until the next message starts a thread. The current
+	// thread is kept until then, so that a chat without messages doesn't lose
+	// it.
+	newThread bool
+
+	// run is the last run this session started, once the stream has reported
+	// its ID. It's set from the stream while Ctrl-C may read it.
+	runMu sync.Mutex
+	run   *ThreadRunParams
+}
+
+// Run starts the REPL.
+func (c *Chat) Run(cmd ChatCmd) error {
+	opts, err := cmd.RunOptions()
+	if err != nil {
+		return err
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:                 "> ",
+		HistoryFile:            path.Join(c.cfg.AppDir, "chat_history"),
+		DisableAutoSaveHistory: true,
+		HistorySearchFold:      true,
+	})
+	if err != nil {
+		return err
+	}
+	defer rl.Close()
+
+	s := &chatSession{Chat: c, rl: rl, cmd: cmd, opts: opts, newThread: !cmd.ContinueThread}
+	s.parser = &InputParser{Stdin: os.Stdin}
+
+	fmt.Fprintln(rl.Stderr(), "/help for commands")
+
+	for {
+		input, err := s.readMessage()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if input == "" {
+			continue
+		}
+
+		rl.SaveHistory(input)
+
+		if strings.HasPrefix(input, "/") {
+			err = s.command(input)
+		} else {
+			err = s.send(&InputText{Text: input})
+		}
+
+		// a failed message or command doesn't end the session
+		if err != nil {
+			fmt.Fprintln(rl.Stderr(), err)
+		}
+	}
+}
+
+// readMessage reads a possibly multi-line message.
+func (s *chatSession) readMessage() (string, error) {
+	var lines []string
+	var quoted bool
+
+	defer s.rl.SetPrompt("> ")
+
+	for {
+		line, err := s.rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			// discard the message being entered
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case strings.TrimSpace(line) == `"""`:
+			quoted = !quoted
+			if !quoted {
+				return strings.Join(lines, "\n"), nil
+			}
+		case quoted:
+			lines = append(lines, line)
+		case strings.HasSuffix(line, `\`):
+			lines = append(lines, strings.TrimSuffix(line, `\`))
+		default:
+			lines = append(lines, line)
+			return strings.TrimSpace(strings.Join(lines, "\n")), nil
+		}
+
+		s.rl.SetPrompt(". ")
+	}
+}
+
+func (s *chatSession) send(inputs ...json.Marshaler) error {
+	err := s.TR.checkBudget(s.cmd.Force)
+	if err != nil {
+		return err
+	}
+
+	assistantID, err := s.AM.CurrentAssistantID()
+	if err != nil {
+		return err
+	}
+
+	threadID, err := s.threadID()
+	if err != nil {
+		return err
+	}
+
+	content := append(s.pending, inputs...)
+	s.pending = nil
+
+	// chat has no variables, but a composed message may use a template
+	err = renderTemplates(content, nil)
+	if err != nil {
+		return err
+	}
+
+	messages, err := NewMessages(content)
+	if err != nil {
+		return err
+	}
+
+	out, flush := markdownWriter(s.cmd.Markdown)
+	defer flush()
+
+	// cancel the run on Ctrl-C, which ends the stream with thread.run.cancelled
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	s.setRun(nil)
+
+	go func() {
+		for {
+			select {
+			case <-interrupt:
+				run := s.lastRun()
+				if run == nil {
+					// the run hasn't been created yet, so there's nothing to cancel
+					fmt.Fprintln(os.Stderr, "run not started yet, ignoring Ctrl-C")
+					continue
+				}
+
+				err := s.RM.Cancel(run.ThreadID, run.RunID)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "cancel:", err)
+				}
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	previousThreadID, err := s.appDB.CurrentThreadID()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.TR.Run(RunParams{
+		AssistantID: assistantID,
+		ThreadID:    threadID,
+		Messages:    messages,
+		Options:     s.opts,
+		Tools:       s.cmd.Tools,
+		OnRunCreated: func(threadID, runID string) {
+			s.setRun(&ThreadRunParams{ThreadID: threadID, RunID: runID})
+		},
+	}, out)
+
+	// the run becomes the current thread once it's created, even if it fails
+	currentThreadID, currentErr := s.appDB.CurrentThreadID()
+	if currentErr == nil && currentThreadID != previousThreadID {
+		s.newThread = false
+	}
+
+	return err
+}
+
+// threadID returns the thread to send to, or "" to start a new one.
+func (s *chatSession) threadID() (string, error) {
+	if s.newThread {
+		return "", nil
+	}
+
+	return s.appDB.CurrentThreadID()
+}
+
+func (s *chatSession) setRun(run *ThreadRunParams) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	s.run = run
+}
+
+func (s *chatSession) lastRun() *ThreadRunParams {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.run
+}
+
+func (s *chatSession) command(input string) error {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	w := s.rl.Stderr()
+
+	switch name {
+	case "/help":
+		fmt.Fprint(w, chatHelp)
+	case "/thread":
+		switch arg {
+		case "":
+			threadID, err := s.threadID()
+			if err != nil {
+				return err
+			}
+			if threadID == "" {
+				threadID = "(new thread)"
+			}
+			fmt.Fprintln(w, threadID)
+		case "new":
+			s.newThread = true
+		default:
+			s.newThread = false
+			return s.appDB.PutCurrentThreadID(arg)
+		}
+	case "/assistant":
+		if arg == "" {
+			assistantID, err := s.AM.CurrentAssistantID()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, assistantID)
+			return nil
+		}
+
+		return s.AM.Use(arg)
+	case "/edit":
+		assistantID, err := s.AM.CurrentAssistantID()
+		if err != nil {
+			return err
+		}
+
+		threadID, err := s.threadID()
+		if err != nil {
+			return err
+		}
+
+		inputs, err := s.TR.Compose(assistantID, threadID, s.parser)
+		if errors.Is(err, ErrEmptyMessage) {
+			fmt.Fprintln(w, err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.TR.uploadInputs(inputs)
+		if err != nil {
+			return err
+		}
+
+		return s.send(inputs...)
+	case "/file", "/image", "/attach":
+		if arg == "" {
+			return s.usage("%s <path>", name)
+		}
+
+		kind := strings.TrimPrefix(name, "/")
+		input, err := s.parser.Parse(kind + ":" + arg)
+		if err == nil {
+			err = s.TR.uploadInputs([]json.Marshaler{input})
+		}
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return nil
+		}
+
+		s.pending = append(s.pending, input)
+		fmt.Fprintf(w, "attached %s to the next message\n", arg)
+	case "/tools":
+		if arg == "" {
+			fmt.Fprintln(w, s.cmd.Tools)
+			return nil
+		}
+
+		s.cmd.Tools = arg
+	case "/cancel":
+		// a reply that ended with an error may leave its run in progress
+		run := s.lastRun()
+		if run == nil {
+			fmt.Fprintln(w, "no run to cancel")
+			return nil
+		}
+
+		err := s.RM.Cancel(run.ThreadID, run.RunID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "cancelled %s\n", run.RunID)
+	default:
+		fmt.Fprintf(w, "unknown command: %s\n", name)
+	}
+
+	return nil
+}
+
+func (s *chatSession) usage(format string, args ...any) error {
+	fmt.Fprintf(s.rl.Stderr(), "usage: "+format+"\n", args...)
+	return nil
+}
@@ -5,19 +5,71 @@ package gpt
 type Args struct {
 	Assistant *AssistantCmdScope `arg:"subcommand:assistant" help:"manage assistants"`
 	Send      *SendCmdScope      `arg:"subcommand:send" help:"run a message in a thread"`
+	Chat      *ChatCmd           `arg:"subcommand:chat" help:"chat interactively in a thread"`
+	Retry     *RetryCmd          `arg:"subcommand:retry" help:"regenerate the last reply of the current thread"`
 	Thread    *ThreadCmdScope    `arg:"subcommand:thread" help:"manage threads"`
 	Run       *RunCmdScope       `arg:"subcommand:run" help:"manage runs"`
+	Usage     *UsageCmd          `arg:"subcommand:usage" help:"report token usage and cost"`
+	Budget    *BudgetCmdScope    `arg:"subcommand:budget" help:"manage spending budgets"`
+	Files     *FilesCmdScope     `arg:"subcommand:files" help:"manage uploaded files"`
+	Prompt    *PromptCmdScope    `arg:"subcommand:prompt" help:"manage saved prompts"`
+	Tokens    *TokensCmd         `arg:"subcommand:tokens" help:"estimate the tokens of inputs, offline"`
 }
 
 type SendCmdScope struct {
-	Inputs         []string `arg:"positional,required"`
+	Inputs         []string `arg:"positional"`
+	Literal        bool     `arg:"--literal" help:"treat inputs without a kind as text, never as file paths"`
+	Assistant      string   `arg:"--assistant" help:"send to this assistant instead of the current one"`
 	ContinueThread bool     `arg:"--continue,-c" help:"run message using the current thread"`
+	Edit           bool     `arg:"--edit,-e" help:"compose the message in $EDITOR"`
+	Conversation   string   `arg:"--conversation" help:"send the messages of a JSONL or markdown file before the inputs"`
 	Tools          string   `arg:"--tools" help:"process tool use with the given command"`
+	Markdown       bool     `arg:"--markdown,-m" help:"render markdown replies when stdout is a terminal"`
+	Force          bool     `arg:"--force" help:"send even if a budget or the token limit is exceeded"`
+	TokenLimit     int      `arg:"--token-limit" help:"refuse to send a message estimated over this many tokens"`
+	Schema         string   `arg:"--schema" help:"constrain the reply to the JSON schema file, and print only the validated JSON"`
+	SchemaRetries  int      `arg:"--schema-retries" help:"ask the assistant to correct a reply that fails schema validation, up to N times"`
+	Vars           []string `arg:"--var,separate" help:"set a variable of tmpl: inputs, as key=value"`
+	VarsFile       string   `arg:"--vars" help:"read the variables of tmpl: inputs from a JSON file"`
+
+	RunOptionsArgs
 
 	// TODO remove
 	Message string
 }
 
+type ChatCmd struct {
+	ContinueThread bool   `arg:"--continue,-c" help:"chat in the current thread"`
+	Tools          string `arg:"--tools" help:"process tool use with the given command"`
+	Markdown       bool   `arg:"--markdown,-m" help:"render markdown replies when stdout is a terminal"`
+	Force          bool   `arg:"--force" help:"send even if a budget is exceeded"`
+
+	RunOptionsArgs
+}
+
+type RetryCmd struct {
+	Assistant string `arg:"--assistant" help:"regenerate with this assistant instead of the current one"`
+	Tools     string `arg:"--tools" help:"process tool use with the given command"`
+	Markdown  bool   `arg:"--markdown,-m" help:"render markdown replies when stdout is a terminal"`
+	Force     bool   `arg:"--force" help:"send even if a budget is exceeded"`
+
+	RunOptionsArgs
+}
+
+// RunOptionsArgs are flags that override the assistant's settings for a run.
+type RunOptionsArgs struct {
+	Model                  string   `arg:"--model" help:"override the assistant's model" json:"model,omitempty"`
+	Instructions           string   `arg:"--instructions" help:"override the assistant's instructions" json:"instructions,omitempty"`
+	AdditionalInstructions string   `arg:"--additional-instructions" help:"append to the assistant's instructions" json:"additional_instructions,omitempty"`
+	Temperature            *float64 `arg:"--temperature" help:"sampling temperature, between 0 and 2" json:"temperature,omitempty"`
+	TopP                   *float64 `arg:"--top-p" help:"nucleus sampling probability mass" json:"top_p,omitempty"`
+	ToolChoice             string   `arg:"--tool-choice" help:"none, auto, required, file_search, code_interpreter, or a function name" json:"tool_choice,omitempty"`
+	ParallelToolCalls      *bool    `arg:"--parallel-tool-calls" help:"allow parallel function calls (--parallel-tool-calls=false to disable)" json:"parallel_tool_calls,omitempty"`
+	MaxPromptTokens        int      `arg:"--max-prompt-tokens" help:"max prompt tokens used over the run" json:"max_prompt_tokens,omitempty"`
+	MaxCompletionTokens    int      `arg:"--max-completion-tokens" help:"max completion tokens used over the run" json:"max_completion_tokens,omitempty"`
+	Truncation             string   `arg:"--truncation" help:"thread truncation strategy: auto, or last_messages:N" json:"truncation,omitempty"`
+}
+
 type ThreadMessagesCmd struct {
 }
 
@@ -29,6 +81,11 @@ type ThreadCmdScope struct {
 	Show     *ThreadShowCmd     `arg:"subcommand:show" help:"show current thread info"`
 	Messages *ThreadMessagesCmd `arg:"subcommand:messages" help:"list messages of current thread"`
 	Use      *ThreadUseCmd      `arg:"subcommand:use" help:"use thread"`
+	Fork     *ThreadForkCmd     `arg:"subcommand:fork" help:"copy the current thread into a new thread, and use it"`
+}
+
+type ThreadForkCmd struct {
+	At string `arg:"--at" help:"fork after this message (default: the last message)"`
 }
 
 type ThreadShowCmd struct {
@@ -47,6 +104,40 @@ type AssistantCreateCmd struct {
 	AssistantFile string `arg:"positional,required"`
 }
 
+type AssistantApplyCmd struct {
+	File string `arg:"positional,required" help:"assistant definition, e.g. assistant.jsonc"`
+}
+
+type AssistantDiffCmd struct {
+	File string `arg:"positional,required" help:"assistant definition, e.g. assistant.jsonc"`
+	ID   string `arg:"positional" help:"assistant to diff against, instead of the one the definition was applied to"`
+}
+
+type AssistantExportCmd struct {
+	ID string `arg:"positional" help:"assistant to export, instead of the current one"`
+}
+
+// AssistantEditArgs are the changes update and clone make to an assistant.
+type AssistantEditArgs struct {
+	Set              []string `arg:"--set,separate" help:"set a field, as key=value, e.g. model=gpt-4o or metadata.author=me"`
+	InstructionsFile string   `arg:"--instructions-file" help:"read the instructions from a file"`
+}
+
+type AssistantUpdateCmd struct {
+	ID string `arg:"positional,required"`
+	AssistantEditArgs
+}
+
+type AssistantDeleteCmd struct {
+	ID  string `arg:"positional,required"`
+	Yes bool   `arg:"--yes" help:"delete without asking for confirmation"`
+}
+
+type AssistantCloneCmd struct {
+	ID string `arg:"positional" help:"assistant to clone, instead of the current one"`
+	AssistantEditArgs
+}
+
 type AssistantShowCmd struct {
 	ID string `arg:"positional"`
 }
@@ -56,6 +147,12 @@ type AssistantCmdScope struct {
 	List   *AssistantListCmd   `arg:"subcommand:ls" help:"list assistants"`
 	Use    *AssistantUseCmd    `arg:"subcommand:use" help:"use assistant"`
 	Create *AssistantCreateCmd `arg:"subcommand:create" help:"create assistant"`
+	Apply  *AssistantApplyCmd  `arg:"subcommand:apply" help:"create or update an assistant from a definition file"`
+	Diff   *AssistantDiffCmd   `arg:"subcommand:diff" help:"diff a definition file against the remote assistant"`
+	Export *AssistantExportCmd `arg:"subcommand:export" help:"print an assistant as a definition file"`
+	Update *AssistantUpdateCmd `arg:"subcommand:update" help:"modify an assistant"`
+	Delete *AssistantDeleteCmd `arg:"subcommand:delete" help:"delete an assistant"`
+	Clone  *AssistantCloneCmd  `arg:"subcommand:clone" help:"create a copy of an assistant, with modifications"`
 }
 
 type RunCmdScope struct {
@@ -69,3 +166,77 @@ type RunListStepsCmd struct {
 type RunShowCmd struct {
 	ID string `arg:"positional"`
 }
+
+type UsageCmd struct {
+	By    string `arg:"--by" default:"day" help:"group by day, assistant, thread or model"`
+	Since string `arg:"--since" help:"only include runs created since this date (YYYY-MM-DD)"`
+}
+
+type BudgetCmdScope struct {
+	Show *BudgetShowCmd `arg:"subcommand:show" help:"show budgets and spending"`
+	Set  *BudgetSetCmd  `arg:"subcommand:set" help:"set budgets"`
+}
+
+type BudgetShowCmd struct {
+}
+
+// BudgetSetCmd changes only the limits that are given. A limit of 0 removes it.
+type BudgetSetCmd struct {
+	DailyTokens   *int64   `arg:"--daily-tokens" help:"daily token limit"`
+	MonthlyTokens *int64   `arg:"--monthly-tokens" help:"monthly token limit"`
+	DailyUSD      *float64 `arg:"--daily-usd" help:"daily spending limit in USD"`
+	MonthlyUSD    *float64 `arg:"--monthly-usd" help:"monthly spending limit in USD"`
+	WarnAt        *float64 `arg:"--warn-at" help:"fraction of a budget at which to warn (default 0.8)"`
+}
+
+type FilesCmdScope struct {
+	GC *FilesGCCmd `arg:"subcommand:gc" help:"delete uploaded files that no thread refers to anymore"`
+}
+
+type FilesGCCmd struct {
+	Yes bool `arg:"--yes" help:"delete the files, instead of only listing them"`
+}
+
+type PromptCmdScope struct {
+	Save   *PromptSaveCmd   `arg:"subcommand:save" help:"save inputs, an assistant, run options and variables as a named prompt"`
+	List   *PromptListCmd   `arg:"subcommand:ls" help:"list saved prompts"`
+	Show   *PromptShowCmd   `arg:"subcommand:show" help:"show a saved prompt"`
+	Remove *PromptRemoveCmd `arg:"subcommand:rm" help:"delete a saved prompt"`
+	Run    *PromptRunCmd    `arg:"subcommand:run" help:"send a saved prompt, with more inputs and overrides"`
+}
+
+type PromptSaveCmd struct {
+	Name      string   `arg:"positional,required"`
+	Inputs    []string `arg:"positional"`
+	Literal   bool     `arg:"--literal" help:"treat inputs without a kind as text, never as file paths"`
+	Assistant string   `arg:"--assistant" help:"send to this assistant instead of the current one"`
+	Tools     string   `arg:"--tools" help:"process tool use with the given command"`
+	Vars      []string `arg:"--var,separate" help:"set a variable of tmpl: inputs, as key=value"`
+
+	RunOptionsArgs
+}
+
+type PromptListCmd struct {
+}
+
+type PromptShowCmd struct {
+	Name string `arg:"positional,required"`
+}
+
+type PromptRemoveCmd struct {
+	Name string `arg:"positional,required"`
+}
+
+type PromptRunCmd struct {
+	Name string `arg:"positional,required"`
+
+	SendCmdScope
+}
+
+type TokensCmd struct {
+	Inputs   []string `arg:"positional"`
+	Literal  bool     `arg:"--literal" help:"treat inputs without a kind as text, never as file paths"`
+	Model    string   `arg:"--model" default:"gpt-4o" help:"count with the tokenizer of this model"`
+	Vars     []string `arg:"--var,separate" help:"set a variable of tmpl: inputs, as key=value"`
+	VarsFile string   `arg:"--vars" help:"read the variables of tmpl: inputs from a JSON file"`
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
 	"github.com/hayeah/gpt"
 )
 
@@ -11,6 +15,13 @@ func main() {
 	}
 
 	err = app.Run()
+
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitErr.ExitCode())
+	}
+
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,149 @@
+package gpt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const composeScissors = "# ------------------------ >8 ------------------------"
+
+// ErrEmptyMessage is returned when the composed message is left empty.
+var ErrEmptyMessage = errors.New("aborting due to empty message")
+
+// ComposeTemplate is the initial content of the file opened in the editor.
+func ComposeTemplate(assistantID, threadID, lastReply string) string {
+	if threadID == "" {
+		threadID = "(new thread)"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n%s\n", composeScissors)
+	fmt.Fprintln(&b, "# Write the message above the line. Everything below it is ignored.")
+	fmt.Fprintln(&b, "# A line like @file:path adds that input to the message. The inputs are:")
+	fmt.Fprintln(&b, "#   @text:..., @file:path, @image:url|path, @attach:path[#tools], @glob:pattern, @dir:path,")
+	fmt.Fprintln(&b, "#   @git:diff|staged|log|show:rev:path, @tmpl:path, @pdf:path[#pages], @html:path, @docx:path")
+	fmt.Fprintln(&b, "# A line like @as:assistant starts a new message with that role, and @meta:key=value sets")
+	fmt.Fprintln(&b, "# the metadata of the message.")
+	fmt.Fprintln(&b, "#")
+	fmt.Fprintf(&b, "# assistant: %s\n", assistantID)
+	fmt.Fprintf(&b, "# thread:    %s\n", threadID)
+
+	if lastReply != "" {
+		fmt.Fprintln(&b, "#")
+		fmt.Fprintln(&b, "# last reply:")
+		fmt.Fprintln(&b, "#")
+		for _, line := range strings.Split(strings.TrimRight(lastReply, "\n"), "\n") {
+			fmt.Fprintf(&b, "# %s\n", line)
+		}
+	}
+
+	return b.String()
+}
+
+// ParseComposed converts an edited message to inputs. Text is kept as is,
+// except that lines of the form @kind:spec, for a known input kind, are parsed
+// with the parser.
+func ParseComposed(content string, parser *InputParser) ([]json.Marshaler, error) {
+	content, _, _ = strings.Cut(content, composeScissors)
+
+	var ms []json.Marshaler
+	var text []string
+
+	flushText := func() {
+		t := strings.TrimSpace(strings.Join(text, "\n"))
+		if t != "" {
+			ms = append(ms, &InputText{Text: t})
+		}
+		text = nil
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		spec, ok := strings.CutPrefix(strings.TrimSpace(line), "@")
+		kind, _, _ := strings.Cut(spec, ":")
+		if !ok || !strings.Contains(spec, ":") || !IsInputKind(kind) {
+			text = append(text, line)
+			continue
+		}
+
+		flushText()
+
+		m, err := parser.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", line, err)
+		}
+		ms = append(ms, m)
+	}
+	flushText()
+
+	if len(ms) == 0 {
+		return nil, ErrEmptyMessage
+	}
+
+	return ms, nil
+}
+
+// EditFile opens a file in $VISUAL or $EDITOR, falling back to vi.
+func EditFile(file string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// run with sh so that the editor can have arguments, e.g. "code --wait"
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "--", file)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+
+	return nil
+}
+
+// Compose opens the editor to write a message for the thread. The inputs in the
+// message are parsed with the command's parser, which may already have read
+// stdin.
+func (tr *ThreadRunner) Compose(assistantID, threadID string, parser *InputParser) ([]json.Marshaler, error) {
+	var lastReply string
+	if threadID != "" {
+		var err error
+		lastReply, err = tr.Threads.LastReply(threadID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.CreateTemp("", "gpt-message-*.md")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(ComposeTemplate(assistantID, threadID, lastReply))
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = EditFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseComposed(string(content), parser)
+}
@@ -0,0 +1,46 @@
+package gpt
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseComposed(t *testing.T) {
+	assert := assert.New(t)
+
+	content := "Review this:\n@file:testdata/input.md\n\nThanks!\n@alice: see you\n" +
+		ComposeTemplate("asst_1", "thread_1", "previous\nreply")
+
+	ms, err := ParseComposed(content, &InputParser{})
+	assert.NoError(err)
+
+	actual, err := json.Marshal(ms)
+	assert.NoError(err)
+	assert.JSONEq(`[
+		{"type":"text","text":"Review this:"},
+		{"type":"text","text":"hello from file input\n"},
+		{"type":"text","text":"Thanks!\n@alice: see you"}
+	]`, string(actual))
+
+	_, err = ParseComposed(ComposeTemplate("asst_1", "", ""), &InputParser{})
+	assert.ErrorIs(err, ErrEmptyMessage)
+
+	// stdin already read by the command line can't be read again
+	parser := &InputParser{Stdin: strings.NewReader("from stdin")}
+	_, err = parser.Parse("-")
+	assert.NoError(err)
+	_, err = ParseComposed("@file:-", parser)
+	assert.ErrorContains(err, "already read")
+}
+
+func TestComposeTemplateHint(t *testing.T) {
+	assert := assert.New(t)
+
+	hint := ComposeTemplate("asst_1", "", "")
+	for _, kind := range inputKinds {
+		assert.Contains(hint, "@"+kind+":")
+	}
+}
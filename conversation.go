@@ -0,0 +1,273 @@
+package gpt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// InputRole starts a new message with the role, e.g. as:assistant. The inputs
+// before the first marker are a user message.
+type InputRole struct {
+	Role string
+}
+
+// InputMetadata sets metadata on the message it's in, e.g. meta:source=import.
+type InputMetadata struct {
+	Key   string
+	Value string
+}
+
+// Implementing the MarshalJSON method for InputRole
+func (ir *InputRole) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("as:%s is not content, build messages with NewMessages", ir.Role)
+}
+
+// Implementing the MarshalJSON method for InputMetadata
+func (im *InputMetadata) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("meta:%s is not content, build messages with NewMessages", im.Key)
+}
+
+// ParseRoleInput parses a message role. The Assistants API only takes user
+// and assistant messages.
+func ParseRoleInput(role string) (*InputRole, error) {
+	if role != "user" && role != "assistant" {
+		return nil, fmt.Errorf("invalid role: %q (expected user or assistant)", role)
+	}
+
+	return &InputRole{Role: role}, nil
+}
+
+// ParseMetadataInput parses a key=value metadata pair.
+func ParseMetadataInput(spec string) (*InputMetadata, error) {
+	key, value, ok := strings.Cut(spec, "=")
+	if !ok || key == "" {
+		return nil, fmt.Errorf("invalid metadata: %q (expected key=value)", spec)
+	}
+
+	return &InputMetadata{Key: key, Value: value}, nil
+}
+
+// NewMessages splits inputs into messages at the as:<role> markers.
+func NewMessages(inputs []json.Marshaler) ([]Message, error) {
+	var messages []Message
+
+	role := "user"
+	var content []json.Marshaler
+	var metadata map[string]string
+
+	flush := func() error {
+		if len(content) == 0 {
+			if metadata != nil {
+				return fmt.Errorf("empty %s message has metadata", role)
+			}
+			return nil
+		}
+
+		m := NewMessage(role, content)
+		m.Metadata = metadata
+		messages = append(messages, m)
+
+		content = nil
+		metadata = nil
+		return nil
+	}
+
+	for _, input := range inputs {
+		switch input := input.(type) {
+		case *InputRole:
+			err := flush()
+			if err != nil {
+				return nil, err
+			}
+
+			role = input.Role
+		case *InputMetadata:
+			if metadata == nil {
+				metadata = map[string]string{}
+			}
+			metadata[input.Key] = input.Value
+		default:
+			content = append(content, input)
+		}
+	}
+
+	err := flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// LoadConversation reads messages from a file, to seed a thread with an
+// imported conversation or few-shot examples. A .md file has messages headed
+// by "## user" or "## assistant" lines. Any other file is JSONL, with a
+// message object per line:
+//
+//	{"role": "user", "content": "text, or an array of content parts", "metadata": {"k": "v"}}
+func LoadConversation(file string) ([]Message, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read conversation: %w", err)
+	}
+
+	var messages []Message
+	if filepath.Ext(file) == ".md" {
+		messages, err = parseMarkdownConversation(string(data))
+	} else {
+		messages, err = parseJSONLConversation(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("conversation %s: %w", file, err)
+	}
+
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("conversation %s: no messages", file)
+	}
+
+	return messages, nil
+}
+
+var conversationRoleHeading = regexp.MustCompile(`(?i)^#{1,6}\s*(user|assistant)\s*$`)
+
+func parseMarkdownConversation(text string) ([]Message, error) {
+	var messages []Message
+	var role string
+	var lines []string
+
+	flush := func() error {
+		content := strings.TrimSpace(strings.Join(lines, "\n"))
+		lines = nil
+
+		if role == "" {
+			if content != "" {
+				return fmt.Errorf("text before the first ## user or ## assistant heading")
+			}
+			return nil
+		}
+
+		if content == "" {
+			return fmt.Errorf("empty %s message", role)
+		}
+
+		messages = append(messages, NewMessage(role, []json.Marshaler{&InputText{Text: content}}))
+		return nil
+	}
+
+	// headings inside a fenced code block are part of the message
+	var fence string
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			lines = append(lines, line)
+			continue
+		}
+
+		if marker := codeFence(trimmed); marker != "" {
+			fence = marker
+			lines = append(lines, line)
+			continue
+		}
+
+		match := conversationRoleHeading.FindStringSubmatch(trimmed)
+		if match == nil {
+			lines = append(lines, line)
+			continue
+		}
+
+		err := flush()
+		if err != nil {
+			return nil, err
+		}
+
+		role = strings.ToLower(match[1])
+	}
+
+	err := flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// codeFence returns the ``` or ~~~ run that opens a fenced code block, or "".
+func codeFence(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 {
+			return line[:n]
+		}
+	}
+
+	return ""
+}
+
+func parseJSONLConversation(data []byte) ([]Message, error) {
+	var messages []Message
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var m struct {
+			Role        string            `json:"role"`
+			Content     json.RawMessage   `json:"content"`
+			Attachments []json.RawMessage `json:"attachments"`
+			Metadata    map[string]string `json:"metadata"`
+		}
+
+		err := json.Unmarshal(line, &m)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		_, err = ParseRoleInput(m.Role)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		message := Message{Role: m.Role, Metadata: m.Metadata}
+
+		var text string
+		var parts []json.RawMessage
+		if json.Unmarshal(m.Content, &text) == nil {
+			message.Content = append(message.Content, &InputText{Text: text})
+		} else if json.Unmarshal(m.Content, &parts) == nil && len(parts) > 0 {
+			for _, part := range parts {
+				message.Content = append(message.Content, part)
+			}
+		} else {
+			return nil, fmt.Errorf("line %d: content must be a string or an array of content parts", n)
+		}
+
+		for _, attachment := range m.Attachments {
+			message.Attachments = append(message.Attachments, attachment)
+		}
+
+		messages = append(messages, message)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
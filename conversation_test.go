@@ -0,0 +1,80 @@
+package gpt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const fewshotJSON = `[
+	{"role": "user", "content": [{"type": "text", "text": "Translate: cat"}]},
+	{"role": "assistant", "content": [{"type": "text", "text": "chat"}]},
+	{"role": "user", "content": [{"type": "text", "text": "Translate: dog"}]}
+]`
+
+func TestLoadConversationMarkdown(t *testing.T) {
+	assert := assert.New(t)
+
+	messages, err := LoadConversation("testdata/fewshot.md")
+	assert.NoError(err)
+
+	actual, err := json.Marshal(messages)
+	assert.NoError(err)
+	assert.JSONEq(fewshotJSON, string(actual))
+
+	_, err = parseMarkdownConversation("preamble\n## user\nhi")
+	assert.Error(err)
+
+	// a heading in a code block doesn't start a message
+	messages, err = parseMarkdownConversation("## user\nfix this:\n```md\n## assistant\n~~~\n```\n## assistant\nok\n~~~~\n# user\n~~~~")
+	assert.NoError(err)
+	if assert.Len(messages, 2) {
+		actual, err = json.Marshal(messages[0].Content)
+		assert.NoError(err)
+		assert.JSONEq(`[{"type": "text", "text": "fix this:\n`+"```md\\n## assistant\\n~~~\\n```"+`"}]`, string(actual))
+	}
+}
+
+func TestLoadConversationJSONL(t *testing.T) {
+	assert := assert.New(t)
+
+	messages, err := LoadConversation("testdata/fewshot.jsonl")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"example": "1"}, messages[0].Metadata)
+
+	messages[0].Metadata = nil
+	actual, err := json.Marshal(messages)
+	assert.NoError(err)
+	assert.JSONEq(fewshotJSON, string(actual))
+
+	_, err = parseJSONLConversation([]byte(`{"role": "system", "content": "hi"}`))
+	assert.ErrorContains(err, "line 1")
+}
+
+func TestNewMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	parser := &InputParser{}
+	inputs, err := parser.ParseAll([]string{
+		"text:Translate: cat", "meta:example=1",
+		"as:assistant", "text:chat",
+		"as:user", "text:Translate: dog",
+	})
+	assert.NoError(err)
+
+	messages, err := NewMessages(inputs)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"example": "1"}, messages[0].Metadata)
+
+	messages[0].Metadata = nil
+	actual, err := json.Marshal(messages)
+	assert.NoError(err)
+	assert.JSONEq(fewshotJSON, string(actual))
+
+	_, err = parser.Parse("as:system")
+	assert.Error(err)
+
+	_, err = NewMessages([]json.Marshaler{&InputMetadata{Key: "k"}, &InputRole{Role: "assistant"}})
+	assert.Error(err)
+}
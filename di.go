@@ -1,9 +1,13 @@
 package gpt
 
 import (
+	"bufio"
 	"embed"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"path"
 	"strings"
@@ -22,6 +26,17 @@ type Config struct {
 	goo.Config
 	OpenAI OpenAIConfig
 	AppDir string
+
+	// Prices overrides the default model prices used by usage reports.
+	Prices map[string]ModelPrice
+
+	// Budget limits spending. If unset, the budget saved by `gpt budget set` is used.
+	Budget *Budget
+
+	Retry RetryConfig
+
+	// TokenLimit refuses to send messages estimated over this many tokens. Defaults to 120000.
+	TokenLimit int
 }
 
 type OpenAIConfig struct {
@@ -52,6 +67,23 @@ func readSecret(prompt string) ([]byte, error) {
 	return secret, nil
 }
 
+// confirm asks the user a yes or no question on the terminal. The answer is
+// no unless it's y or yes.
+func confirm(prompt string) (bool, error) {
+	if !term.IsTerminal(syscall.Stdin) {
+		return false, errors.New("confirm: stdin is not a terminal")
+	}
+
+	fmt.Print(prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("confirm: %w", err)
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
 //go:embed migrations/*.sql
 var migratefs embed.FS
 
@@ -170,8 +202,12 @@ func ProvideOpenAIConfig(cfg *Config) *OpenAIConfig {
 	return &cfg.OpenAI
 }
 
-func ProvideOAI(cfg *Config) *OpenAIV2API {
-	return NewOpenAIV2API(cfg.OpenAI.APIKey)
+func ProvideOAI(cfg *Config, log *slog.Logger) *OpenAIV2API {
+	client := &http.Client{
+		Transport: NewRetryTransport(cfg.Retry, log),
+	}
+
+	return NewOpenAIV2API(cfg.OpenAI.APIKey, client)
 }
 
 // ProvideAppDB provides an AppDB instance.
@@ -195,5 +231,10 @@ var wires = wire.NewSet(
 	wire.Struct(new(ThreadRunner), "*"),
 	wire.Struct(new(AssistantManager), "*"),
 	wire.Struct(new(RunManager), "*"),
+	wire.Struct(new(UsageManager), "*"),
+	wire.Struct(new(BudgetManager), "*"),
+	wire.Struct(new(Chat), "*"),
+	wire.Struct(new(FileManager), "*"),
+	wire.Struct(new(PromptManager), "*"),
 	wire.Struct(new(App), "*"),
 )
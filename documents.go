@@ -0,0 +1,280 @@
+package gpt
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/ledongthuc/pdf"
+)
+
+// parsePDF extracts the text of a PDF, optionally of the pages selected by a
+// range after #, e.g. report.pdf#1-3,7. Scanned PDFs have no text to extract.
+func (p *InputParser) parsePDF(input, spec string) (it *InputText, err error) {
+	// the pdf package panics on some malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			it, err = nil, fmt.Errorf("%s: %v", input, r)
+		}
+	}()
+
+	path, pageRange, _ := strings.Cut(spec, "#")
+
+	data, err := p.read(input, path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", input, err)
+	}
+
+	pages, err := parsePageRange(pageRange, r.NumPage())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", input, err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "File: %s\n", path)
+
+	fonts := map[string]*pdf.Font{}
+	var hasText bool
+	for _, n := range pages {
+		page := r.Page(n)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// cache fonts so that charmaps are parsed once
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+
+		text, err := page.GetPlainText(fonts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: page %d: %w", input, n, err)
+		}
+
+		text = strings.TrimSpace(text)
+		if text != "" {
+			hasText = true
+		}
+
+		fmt.Fprintf(&b, "\n--- page %d ---\n%s\n", n, text)
+	}
+
+	// a damaged PDF reads as pages without text, rather than failing
+	if !hasText {
+		return nil, fmt.Errorf("%s: no text found (the PDF may be scanned or damaged)", input)
+	}
+
+	return &InputText{Text: b.String()}, nil
+}
+
+// parsePageRange parses page numbers like 1-3,7,10- into a list of pages.
+// An empty range is every page. Pages are listed once, in the order they are
+// first given.
+func parsePageRange(spec string, numPages int) ([]int, error) {
+	if spec == "" {
+		spec = "1-"
+	}
+
+	var pages []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page range: %q", spec)
+		}
+
+		to := from
+		if isRange {
+			to = numPages
+			if last != "" {
+				to, err = strconv.Atoi(last)
+				if err != nil {
+					return nil, fmt.Errorf("invalid page range: %q", spec)
+				}
+			}
+		}
+
+		if from < 1 || to > numPages || from > to {
+			return nil, fmt.Errorf("pages %s out of range (the document has %d pages)", part, numPages)
+		}
+
+		for n := from; n <= to; n++ {
+			if !seen[n] {
+				seen[n] = true
+				pages = append(pages, n)
+			}
+		}
+	}
+
+	return pages, nil
+}
+
+// parseHTML converts an HTML page to markdown.
+func (p *InputParser) parseHTML(input, path string) (*InputText, error) {
+	data, err := p.read(input, path)
+	if err != nil {
+		return nil, err
+	}
+
+	converter := md.NewConverter("", true, nil)
+	converter.Remove("head", "script", "style", "noscript")
+
+	text, err := converter.ConvertBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", input, err)
+	}
+
+	return &InputText{Text: fmt.Sprintf("File: %s\n\n%s\n", path, bytes.TrimSpace(text))}, nil
+}
+
+// parseDOCX extracts the text of a Word document as markdown, keeping
+// headings, list items, and tables.
+func (p *InputParser) parseDOCX(input, path string) (*InputText, error) {
+	data, err := p.read(input, path)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := docxText(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", input, err)
+	}
+
+	return &InputText{Text: fmt.Sprintf("File: %s\n\n%s\n", path, text)}, nil
+}
+
+func docxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("not a docx file: %w", err)
+	}
+
+	f, err := zr.Open("word/document.xml")
+	if err != nil {
+		return "", fmt.Errorf("not a docx file: %w", err)
+	}
+	defer f.Close()
+
+	var b strings.Builder
+
+	// the paragraph being read, and the cells of the table row being read
+	var para strings.Builder
+	var prefix string
+	var cells []string
+	var inCell bool
+	var rows int
+
+	dec := xml.NewDecoder(f)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "p":
+				para.Reset()
+				prefix = ""
+			case "pStyle":
+				prefix = docxStylePrefix(xmlAttr(tok, "val"))
+			case "numPr":
+				if prefix == "" {
+					prefix = "- "
+				}
+			case "t":
+				var text string
+				err = dec.DecodeElement(&text, &tok)
+				if err != nil {
+					return "", err
+				}
+				para.WriteString(text)
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			case "tbl":
+				rows = 0
+			case "tr":
+				cells = nil
+			case "tc":
+				inCell = true
+				cells = append(cells, "")
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if inCell {
+					cell := &cells[len(cells)-1]
+					*cell = strings.TrimSpace(*cell + " " + text)
+				} else if text != "" {
+					b.WriteString(prefix + text + "\n\n")
+				}
+			case "tc":
+				inCell = false
+			case "tr":
+				b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+
+				// the first row is the header
+				rows++
+				if rows == 1 {
+					b.WriteString(strings.Repeat("|---", len(cells)) + "|\n")
+				}
+			case "tbl":
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+// docxStylePrefix returns the markdown prefix of a paragraph style, e.g. "## "
+// for Heading2.
+func docxStylePrefix(style string) string {
+	switch {
+	case style == "Title":
+		return "# "
+	case strings.HasPrefix(style, "Heading"):
+		level, err := strconv.Atoi(strings.TrimPrefix(style, "Heading"))
+		if err != nil || level < 1 || level > 6 {
+			return ""
+		}
+		return strings.Repeat("#", level) + " "
+	case strings.HasPrefix(style, "List"):
+		return "- "
+	default:
+		return ""
+	}
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
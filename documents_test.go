@@ -0,0 +1,100 @@
+package gpt
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePDFInput(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := ParseInput("pdf:testdata/pages.pdf#2-")
+	assert.NoError(err)
+	assert.Equal("File: testdata/pages.pdf\n\n--- page 2 ---\nHello from page two\n\n--- page 3 ---\nHello from page three\n", m.(*InputText).Text)
+
+	m, err = ParseInput("pdf:testdata/pages.pdf")
+	assert.NoError(err)
+	assert.Contains(m.(*InputText).Text, "Hello from page one")
+
+	_, err = ParseInput("pdf:testdata/pages.pdf#4")
+	assert.ErrorContains(err, "the document has 3 pages")
+
+	// damaged PDFs are errors, rather than empty text or a panic
+	data, err := os.ReadFile("testdata/pages.pdf")
+	assert.NoError(err)
+
+	dir := t.TempDir()
+	damaged := map[string][]byte{
+		"no-text.pdf":   bytes.ReplaceAll(data, []byte("Tj"), []byte("xx")),
+		"truncated.pdf": append(append([]byte{}, data[:600]...), data[len(data)-200:]...),
+	}
+	for name, content := range damaged {
+		path := filepath.Join(dir, name)
+		assert.NoError(os.WriteFile(path, content, 0644))
+
+		_, err = ParseInput("pdf:" + path)
+		assert.Error(err, name)
+	}
+}
+
+func TestParsePageRange(t *testing.T) {
+	assert := assert.New(t)
+
+	pages, err := parsePageRange("1-3,7,9-", 10)
+	assert.NoError(err)
+	assert.Equal([]int{1, 2, 3, 7, 9, 10}, pages)
+
+	pages, err = parsePageRange("2,2,1-3", 10)
+	assert.NoError(err)
+	assert.Equal([]int{2, 1, 3}, pages)
+
+	_, err = parsePageRange("3-1", 10)
+	assert.Error(err)
+
+	_, err = parsePageRange("one", 10)
+	assert.Error(err)
+}
+
+func TestParseHTMLInput(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := ParseInput("html:testdata/page.html")
+	assert.NoError(err)
+	assert.Equal("File: testdata/page.html\n\n# Release notes\n\nThis release adds **offline** extraction.\n\n- PDF\n- HTML\n", m.(*InputText).Text)
+}
+
+func TestParseDOCXInput(t *testing.T) {
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "notes.docx")
+	f, err := os.Create(file)
+	assert.NoError(err)
+
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("word/document.xml")
+	assert.NoError(err)
+
+	_, err = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Meeting notes</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">We agreed to </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>ship</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>PDF</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Owner</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Task</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>Ada</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Docs</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`))
+	assert.NoError(err)
+	assert.NoError(zw.Close())
+	assert.NoError(f.Close())
+
+	m, err := ParseInput("docx:" + file)
+	assert.NoError(err)
+	assert.Equal("File: "+file+"\n\n# Meeting notes\n\nWe agreed to ship\n\n- PDF\n\n| Owner | Task |\n|---|---|\n| Ada | Docs |\n", m.(*InputText).Text)
+
+	_, err = ParseInput("docx:testdata/page.html")
+	assert.ErrorContains(err, "not a docx file")
+}
@@ -0,0 +1,219 @@
+package gpt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// FileManager uploads files, reusing previous uploads of the same content.
+type FileManager struct {
+	oai *OpenAIV2API
+	db  *sqlx.DB
+}
+
+// Upload uploads a file for the given purpose, and returns its file ID. If
+// the same content was uploaded for the same purpose before, and the remote
+// file still exists, the previous upload is reused.
+func (fm *FileManager) Upload(name string, content []byte, purpose string) (string, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	var fileID string
+	err := fm.db.Get(&fileID, "SELECT file_id FROM uploads WHERE sha256 = ? AND purpose = ?", hash, purpose)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return "", err
+	default:
+		ok, err := fm.exists(fileID)
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			return fileID, nil
+		}
+
+		_, err = fm.db.Exec("DELETE FROM uploads WHERE file_id = ?", fileID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	file, err := fm.oai.UploadFile(name, bytes.NewReader(content), purpose)
+	if err != nil {
+		return "", fmt.Errorf("upload %s: %w", name, err)
+	}
+
+	fileID = file.Get("id").String()
+
+	_, err = fm.db.Exec(`
+		INSERT INTO uploads (sha256, purpose, file_id, filename, bytes) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(sha256, purpose) DO UPDATE SET file_id = excluded.file_id, filename = excluded.filename
+	`, hash, purpose, fileID, name, len(content))
+	if err != nil {
+		return "", fmt.Errorf("record upload: %w", err)
+	}
+
+	return fileID, nil
+}
+
+// exists checks whether a remote file exists.
+func (fm *FileManager) exists(fileID string) (bool, error) {
+	// https://platform.openai.com/docs/api-reference/files/retrieve
+	// GET https://api.openai.com/v1/files/{file_id}
+	res, err := fm.oai.do("GET", "/files/"+fileID, "", nil)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	return true, nil
+}
+
+// GC deletes the remote files that this tool uploaded, and that no message of
+// the threads it created or forked refers to anymore. Cached uploads that no
+// longer exist remotely are forgotten. Files that are not in the upload
+// cache, e.g. those uploaded by others sharing the API key, are never
+// deleted. Nothing is deleted unless confirmed.
+func (fm *FileManager) GC(cmd FilesGCCmd) error {
+	var uploads []struct {
+		FileID   string `db:"file_id"`
+		Purpose  string `db:"purpose"`
+		Filename string `db:"filename"`
+		Bytes    int    `db:"bytes"`
+	}
+	err := fm.db.Select(&uploads, "SELECT file_id, purpose, filename, bytes FROM uploads ORDER BY created_at")
+	if err != nil {
+		return err
+	}
+
+	referenced, err := fm.referencedFiles()
+	if err != nil {
+		return err
+	}
+
+	var garbage []string
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, upload := range uploads {
+		if referenced[upload.FileID] {
+			continue
+		}
+
+		ok, err := fm.exists(upload.FileID)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			_, err = fm.db.Exec("DELETE FROM uploads WHERE file_id = ?", upload.FileID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		garbage = append(garbage, upload.FileID)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", upload.FileID, upload.Purpose, upload.Filename, upload.Bytes)
+	}
+	w.Flush()
+
+	if len(garbage) == 0 {
+		fmt.Println("no unreferenced files")
+		return nil
+	}
+
+	if !cmd.Yes {
+		fmt.Printf("%d unreferenced files. Run with --yes to delete them.\n", len(garbage))
+		return nil
+	}
+
+	for _, fileID := range garbage {
+		// https://platform.openai.com/docs/api-reference/files/delete
+		// DELETE https://api.openai.com/v1/files/{file_id}
+		_, err = fm.oai.doJSON("DELETE", "/files/"+fileID, nil)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+
+		_, err = fm.db.Exec("DELETE FROM uploads WHERE file_id = ?", fileID)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("deleted %d files\n", len(garbage))
+
+	return nil
+}
+
+// referencedFiles returns the IDs of the files that the messages of the
+// threads this tool created or forked refer to, as attachments or images.
+func (fm *FileManager) referencedFiles() (map[string]bool, error) {
+	var threadIDs []string
+	err := fm.db.Select(&threadIDs, `
+		SELECT thread_id FROM runs
+		UNION SELECT thread_id FROM thread_forks
+		UNION SELECT json_extract(value, '$') FROM keys WHERE key = ?
+	`, keyCurrentThread)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := map[string]bool{}
+	for _, threadID := range threadIDs {
+		if threadID == "" {
+			continue
+		}
+
+		after := ""
+		for {
+			query := "?limit=100"
+			if after != "" {
+				query += "&after=" + url.QueryEscape(after)
+			}
+
+			// https://platform.openai.com/docs/api-reference/messages/listMessages
+			// GET https://api.openai.com/v1/threads/{thread_id}/messages
+			r, err := fm.oai.doJSON("GET", "/threads/"+threadID+"/messages"+query, nil)
+			if isNotFound(err) {
+				// the thread was deleted, so are its references
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			messages := r.Get("data").Array()
+			for _, message := range messages {
+				for _, id := range message.Get("attachments.#.file_id").Array() {
+					referenced[id.String()] = true
+				}
+
+				for _, id := range message.Get("content.#(type==image_file)#.image_file.file_id").Array() {
+					referenced[id.String()] = true
+				}
+			}
+
+			if !r.Get("has_more").Bool() || len(messages) == 0 {
+				break
+			}
+
+			after = messages[len(messages)-1].Get("id").String()
+		}
+	}
+
+	return referenced, nil
+}
@@ -0,0 +1,132 @@
+package gpt
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB opens an in-memory database with the migrations applied.
+func newTestDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// every connection to :memory: is a different database
+	db.SetMaxOpenConns(1)
+
+	migrations, err := fs.Glob(migratefs, "migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range migrations {
+		sql, err := fs.ReadFile(migratefs, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.Exec(string(sql))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	return db
+}
+
+// apiRequest is a request the fake API got.
+type apiRequest struct {
+	// Key is "METHOD /path"
+	Key  string
+	Body string
+}
+
+// fakeAPI serves canned responses by "METHOD /path", and records the
+// requests it got. Unknown requests get a 404.
+func fakeAPI(t *testing.T, responses map[string]string) (*OpenAIV2API, *[]apiRequest) {
+	var mu sync.Mutex
+	var requests []apiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		requests = append(requests, apiRequest{Key: key, Body: string(body)})
+		mu.Unlock()
+
+		res, ok := responses[key]
+		if !ok {
+			http.Error(w, `{"error":{"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte(res))
+	}))
+	t.Cleanup(srv.Close)
+
+	oai := NewOpenAIV2API("sk-test", srv.Client())
+	oai.BaseURL = srv.URL
+
+	return oai, &requests
+}
+
+// requestsTo returns the keys of the requests with the given method.
+func requestsTo(requests []apiRequest, method string) []string {
+	var keys []string
+	for _, r := range requests {
+		if strings.HasPrefix(r.Key, method+" ") {
+			keys = append(keys, r.Key)
+		}
+	}
+
+	return keys
+}
+
+func TestFileManagerGC(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	_, err := db.Exec(`
+		INSERT INTO uploads (sha256, purpose, file_id, filename, bytes) VALUES
+			('a', 'assistants', 'file_a', 'a.txt', 1),
+			('b', 'vision', 'file_b', 'b.png', 2),
+			('c', 'assistants', 'file_c', 'c.txt', 3);
+		INSERT INTO runs (run_id, thread_id, assistant_id, model, status) VALUES
+			('run_1', 'thread_1', 'asst_1', 'gpt-4o', 'completed'),
+			('run_2', 'thread_gone', 'asst_1', 'gpt-4o', 'completed');
+	`)
+	assert.NoError(err)
+
+	oai, requests := fakeAPI(t, map[string]string{
+		"GET /threads/thread_1/messages": `{"data": [{"id": "msg_1", "attachments": [{"file_id": "file_a"}]}]}`,
+		"GET /files/file_b":              `{"id": "file_b"}`,
+		"DELETE /files/file_b":           `{"id": "file_b", "deleted": true}`,
+	})
+
+	fm := &FileManager{oai: oai, db: db}
+
+	err = fm.GC(FilesGCCmd{})
+	assert.NoError(err)
+	assert.Empty(requestsTo(*requests, "DELETE"))
+
+	err = fm.GC(FilesGCCmd{Yes: true})
+	assert.NoError(err)
+
+	// only the unreferenced upload is deleted, never files outside the cache
+	assert.Equal([]string{"DELETE /files/file_b"}, requestsTo(*requests, "DELETE"))
+
+	var cached []string
+	err = db.Select(&cached, "SELECT file_id FROM uploads")
+	assert.NoError(err)
+	assert.Equal([]string{"file_a"}, cached)
+}
@@ -0,0 +1,206 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/hayeah/goo/fetch"
+	"github.com/tidwall/gjson"
+)
+
+// maxThreadMessages is the most messages a thread can be created with. The
+// rest are added to the thread one by one.
+const maxThreadMessages = 32
+
+// ThreadFork records that a thread was forked from another.
+type ThreadFork struct {
+	ThreadID        string `db:"thread_id"`
+	ParentThreadID  string `db:"parent_thread_id"`
+	ParentMessageID string `db:"parent_message_id"`
+}
+
+// Fork creates a new thread seeded with the messages of the current thread,
+// up to and including the given message (or all messages if empty), and
+// switches to it.
+func (tm *ThreadManager) Fork(atMessageID string) error {
+	parentID, err := tm.db.CurrentThreadID()
+	if err != nil {
+		return err
+	}
+
+	if parentID == "" {
+		return fmt.Errorf("thread fork: no current thread")
+	}
+
+	messages, err := tm.ListMessages(parentID)
+	if err != nil {
+		return err
+	}
+
+	if atMessageID != "" {
+		i := findMessage(messages, atMessageID)
+		if i < 0 {
+			return fmt.Errorf("thread fork: message %s is not in thread %s", atMessageID, parentID)
+		}
+
+		messages = messages[:i+1]
+	}
+
+	if len(messages) == 0 {
+		return fmt.Errorf("thread fork: thread %s has no messages", parentID)
+	}
+
+	var copies []json.RawMessage
+	for _, message := range messages {
+		m, err := tm.copyMessage(message)
+		if err != nil {
+			return err
+		}
+
+		copies = append(copies, m)
+	}
+
+	first := copies[:min(len(copies), maxThreadMessages)]
+
+	// https://platform.openai.com/docs/api-reference/threads/createThread
+	// POST https://api.openai.com/v1/threads
+	r, err := tm.oai.JSON("POST", "/threads", &fetch.Options{
+		Body: map[string]any{
+			"messages": first,
+			"metadata": map[string]string{"forked_from": parentID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	threadID := r.Get("id").String()
+
+	for _, m := range copies[len(first):] {
+		// https://platform.openai.com/docs/api-reference/messages/createMessage
+		// POST https://api.openai.com/v1/threads/{thread_id}/messages
+		_, err = tm.oai.JSON("POST", "/threads/{{thread_id}}/messages", &fetch.Options{
+			Body: m,
+			PathParams: map[string]string{
+				"thread_id": threadID,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fork := &ThreadFork{
+		ThreadID:        threadID,
+		ParentThreadID:  parentID,
+		ParentMessageID: messages[len(messages)-1].Get("id").String(),
+	}
+
+	_, err = tm.sqldb.NamedExec(`
+		INSERT INTO thread_forks (thread_id, parent_thread_id, parent_message_id)
+		VALUES (:thread_id, :parent_thread_id, :parent_message_id)
+	`, fork)
+	if err != nil {
+		return fmt.Errorf("record fork: %w", err)
+	}
+
+	err = tm.db.PutCurrentThreadID(threadID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("forked %s at %s into %s (%d messages)\n",
+		parentID, fork.ParentMessageID, threadID, len(messages))
+
+	return nil
+}
+
+func findMessage(messages []gjson.Result, messageID string) int {
+	for i, message := range messages {
+		if message.Get("id").String() == messageID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// copyMessage converts a message object to a create message request.
+func (tm *ThreadManager) copyMessage(message gjson.Result) (json.RawMessage, error) {
+	role := message.Get("role").String()
+
+	var content []any
+	for _, part := range message.Get("content").Array() {
+		switch part.Get("type").String() {
+		case "text":
+			// annotations refer to the original thread's files, so keep only the text
+			content = append(content, map[string]string{
+				"type": "text",
+				"text": part.Get("text.value").String(),
+			})
+		case "image_file", "image_url":
+			// only user messages may have images
+			if role == "user" {
+				content = append(content, json.RawMessage(part.Raw))
+			}
+		}
+	}
+
+	if len(content) == 0 {
+		content = append(content, map[string]string{"type": "text", "text": "(empty)"})
+	}
+
+	var attachments []any
+	for _, attachment := range message.Get("attachments").Array() {
+		fileID, err := tm.reusableFile(attachment.Get("file_id").String())
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, map[string]any{
+			"file_id": fileID,
+			"tools":   json.RawMessage(attachment.Get("tools").Raw),
+		})
+	}
+
+	m := map[string]any{
+		"role":    role,
+		"content": content,
+	}
+
+	if len(attachments) > 0 {
+		m["attachments"] = attachments
+	}
+
+	if metadata := message.Get("metadata"); metadata.IsObject() && len(metadata.Map()) > 0 {
+		m["metadata"] = json.RawMessage(metadata.Raw)
+	}
+
+	return json.Marshal(m)
+}
+
+// reusableFile returns a file ID that can be attached to a new message. Files
+// the assistant generated cannot be attached, so they are re-uploaded.
+func (tm *ThreadManager) reusableFile(fileID string) (string, error) {
+	// https://platform.openai.com/docs/api-reference/files/retrieve
+	// GET https://api.openai.com/v1/files/{file_id}
+	file, err := tm.oai.JSON("GET", "/files/{{.}}", &fetch.Options{
+		PathParams: fileID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if file.Get("purpose").String() != "assistants_output" {
+		return fileID, nil
+	}
+
+	content, err := tm.oai.DownloadFile(fileID)
+	if err != nil {
+		return "", err
+	}
+
+	// record the upload, so that files gc can delete it once no thread refers to it
+	return tm.files.Upload(path.Base(file.Get("filename").String()), content, "assistants")
+}
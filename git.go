@@ -0,0 +1,104 @@
+package gpt
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ParseGitInput reads content from the git repository in the current
+// directory. The spec is one of:
+//
+//	diff             unstaged changes in the working tree
+//	diff:<rev>       changes in the working tree since a revision
+//	staged           staged changes
+//	log[:<range>]    commits with their patches, e.g. log:main..HEAD. Defaults to the last commit.
+//	show:<rev>:<path>
+//	                 a file at a revision
+func ParseGitInput(spec string) (*InputText, error) {
+	return parseGitInput("", spec)
+}
+
+// parseGitInput runs git in the given directory, or the current directory if
+// empty.
+func parseGitInput(dir, spec string) (*InputText, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+
+	// a revision like --output=file would be taken as an option
+	if strings.HasPrefix(arg, "-") {
+		return nil, fmt.Errorf("git:%s: revision can't start with -", spec)
+	}
+
+	var args []string
+	var label, lang string
+
+	switch kind {
+	case "diff":
+		args = []string{"diff", "--no-color"}
+		label = "Unstaged changes"
+		if arg != "" {
+			args = append(args, arg)
+			label = "Changes since " + arg
+		}
+		lang = "diff"
+	case "staged":
+		args = []string{"diff", "--no-color", "--cached"}
+		label = "Staged changes"
+		lang = "diff"
+	case "log":
+		args = []string{"log", "--no-color", "--patch", "--stat"}
+		if arg == "" {
+			args = append(args, "-1")
+			label = "Last commit"
+		} else {
+			args = append(args, arg)
+			label = "Commits " + arg
+		}
+		lang = "diff"
+	case "show":
+		rev, path, ok := strings.Cut(arg, ":")
+		if !ok || rev == "" || path == "" {
+			return nil, fmt.Errorf("git:show: expected git:show:<rev>:<path>, got %q", spec)
+		}
+
+		args = []string{"show", "--no-color", rev + ":" + path}
+		label = fmt.Sprintf("File: %s at %s", path, rev)
+		lang = sourceLanguage(path)
+	default:
+		return nil, fmt.Errorf("invalid git input: %q (expected diff, staged, log, or show)", kind)
+	}
+
+	out, err := runGit(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, fmt.Errorf("git:%s: no changes", spec)
+	}
+
+	if kind != "show" {
+		label = fmt.Sprintf("%s (git %s):", label, strings.Join(args, " "))
+	}
+
+	var b strings.Builder
+	writeFenced(&b, label, lang, out)
+
+	return &InputText{Text: b.String()}, nil
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package gpt
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGitInput(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		_, err := runGit(dir, args...)
+		assert.NoError(err)
+	}
+
+	write := func(content string) {
+		assert.NoError(os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0644))
+	}
+
+	git("init", "-q")
+	write("package main\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+
+	_, err := parseGitInput(dir, "diff")
+	assert.ErrorContains(err, "no changes")
+
+	write("package main\n\nfunc main() {}\n")
+	git("add", ".")
+
+	staged, err := parseGitInput(dir, "staged")
+	assert.NoError(err)
+	assert.Contains(staged.Text, "Staged changes (git diff --no-color --cached):\n```diff\n")
+	assert.Contains(staged.Text, "+func main() {}\n")
+
+	git("commit", "-q", "-m", "second")
+
+	log, err := parseGitInput(dir, "log:HEAD~1..HEAD")
+	assert.NoError(err)
+	assert.Contains(log.Text, "second")
+	assert.NotContains(log.Text, "first")
+
+	show, err := parseGitInput(dir, "show:HEAD~1:main.go")
+	assert.NoError(err)
+	assert.Equal("File: main.go at HEAD~1\n```go\npackage main\n```\n", show.Text)
+
+	_, err = parseGitInput(dir, "show:HEAD")
+	assert.Error(err)
+
+	// color.ui=always doesn't add escape codes
+	git("config", "color.ui", "always")
+	log, err = parseGitInput(dir, "log")
+	assert.NoError(err)
+	assert.NotContains(log.Text, "\x1b[")
+
+	_, err = parseGitInput(dir, "diff:--output="+filepath.Join(dir, "out"))
+	assert.ErrorContains(err, "can't start with -")
+	assert.NoFileExists(filepath.Join(dir, "out"))
+
+	_, err = parseGitInput(dir, "blame")
+	assert.Error(err)
+}
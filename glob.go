@@ -0,0 +1,265 @@
+package gpt
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/bmatcuk/doublestar/v4"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// maxSourceBytes caps the total size of the files a glob or dir input expands
+// to, so that a broad pattern doesn't blow through the context window.
+const maxSourceBytes = 512 << 10
+
+// ParseGlobInput reads the files matching a pattern like src/**/*.go.
+func ParseGlobInput(pattern string) (*InputText, error) {
+	base, _ := doublestar.SplitPattern(filepath.ToSlash(pattern))
+
+	paths, err := doublestar.FilepathGlob(pattern, doublestar.WithFilesOnly())
+	if err != nil {
+		return nil, fmt.Errorf("glob %s: %w", pattern, err)
+	}
+
+	ignores, err := newGitignores(filepath.FromSlash(base))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, path := range paths {
+		if !ignores.ignored(path, false) {
+			files = append(files, path)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("glob %s: no files match", pattern)
+	}
+
+	return readSourceFiles(pattern, files)
+}
+
+// ParseDirInput reads the files in a directory, recursively.
+func ParseDirInput(dir string) (*InputText, error) {
+	ignores, err := newGitignores(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != dir && ignores.ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dir %s: %w", dir, err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("dir %s: no files", dir)
+	}
+
+	return readSourceFiles(dir, files)
+}
+
+// readSourceFiles concatenates text files, each headed by its path and fenced
+// by its language. Binary files are skipped.
+func readSourceFiles(spec string, paths []string) (*InputText, error) {
+	slices.Sort(paths)
+
+	var b strings.Builder
+	var total int
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if isBinary(content) {
+			continue
+		}
+
+		total += len(content)
+		if total > maxSourceBytes {
+			return nil, fmt.Errorf("%s: files exceed %d bytes, narrow it down", spec, maxSourceBytes)
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+
+		writeFenced(&b, "File: "+filepath.ToSlash(path), sourceLanguage(path), content)
+	}
+
+	if b.Len() == 0 {
+		return nil, fmt.Errorf("%s: only binary files", spec)
+	}
+
+	return &InputText{Text: b.String()}, nil
+}
+
+// writeFenced writes content in a code block headed by a label.
+func writeFenced(b *strings.Builder, label, lang string, content []byte) {
+	// use a fence longer than any backtick run in the content
+	fence := "```"
+	for bytes.Contains(content, []byte(fence)) {
+		fence += "`"
+	}
+
+	fmt.Fprintf(b, "%s\n", label)
+	fmt.Fprintf(b, "%s%s\n", fence, lang)
+	b.Write(content)
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "%s\n", fence)
+}
+
+// isBinary guesses whether content is binary the way git does, by looking for
+// a NUL byte near the start. Invalid UTF-8 is treated as binary too.
+func isBinary(content []byte) bool {
+	head := content[:min(len(content), 8000)]
+	return bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(content)
+}
+
+// sourceLanguage returns the fence language for a file, e.g. "go".
+func sourceLanguage(path string) string {
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil || len(lexer.Config().Aliases) == 0 {
+		return ""
+	}
+
+	return lexer.Config().Aliases[0]
+}
+
+// gitignores matches paths against the .gitignore files of the repository
+// they are in, or of the directory the input started from if there's no
+// repository.
+type gitignores struct {
+	root  string
+	files map[string]gitignoreFile
+}
+
+// gitignoreFile is a .gitignore with a rule per pattern line, since a
+// !negation may re-include a path that another file ignores.
+type gitignoreFile []gitignoreRule
+
+type gitignoreRule struct {
+	pattern *ignore.GitIgnore
+	negate  bool
+}
+
+// match reports whether the last pattern that matches name ignores it. ok is
+// false if no pattern matches.
+func (f gitignoreFile) match(name string) (ignored, ok bool) {
+	for _, rule := range f {
+		if rule.pattern.MatchesPath(name) {
+			ignored, ok = !rule.negate, true
+		}
+	}
+
+	return ignored, ok
+}
+
+func newGitignores(base string) (*gitignores, error) {
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return nil, err
+	}
+
+	root := abs
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			root = dir
+			break
+		}
+
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	return &gitignores{root: root, files: map[string]gitignoreFile{}}, nil
+}
+
+func (g *gitignores) ignored(path string, isDir bool) bool {
+	// never read the repository itself, e.g. .git/config with a glob like **/*
+	if slices.Contains(strings.Split(filepath.ToSlash(path), "/"), ".git") {
+		return true
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(g.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	// check the .gitignore of every directory from the root down to the path
+	dirs := []string{g.root}
+	if parent := filepath.Dir(rel); parent != "." {
+		dir := g.root
+		for _, part := range strings.Split(parent, string(filepath.Separator)) {
+			dir = filepath.Join(dir, part)
+			dirs = append(dirs, dir)
+		}
+	}
+
+	// as in git, the deepest .gitignore with a matching pattern decides
+	var ignored bool
+	for _, dir := range dirs {
+		name, _ := filepath.Rel(dir, abs)
+		name = filepath.ToSlash(name)
+		if isDir {
+			name += "/"
+		}
+
+		if match, ok := g.load(dir).match(name); ok {
+			ignored = match
+		}
+	}
+
+	return ignored
+}
+
+func (g *gitignores) load(dir string) gitignoreFile {
+	f, ok := g.files[dir]
+	if ok {
+		return f
+	}
+
+	// empty if the directory has no .gitignore
+	data, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	for _, line := range strings.Split(string(data), "\n") {
+		pattern, negate := strings.CutPrefix(strings.TrimRight(line, "\r"), "!")
+		f = append(f, gitignoreRule{pattern: ignore.CompileIgnoreLines(pattern), negate: negate})
+	}
+
+	g.files[dir] = f
+	return f
+}
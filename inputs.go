@@ -2,10 +2,13 @@ package gpt
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -18,6 +21,27 @@ type InputImageURL struct {
 	Detail string // "low" | "high" | "auto"
 }
 
+// InputImageFile is a local image, which is uploaded before it's sent.
+type InputImageFile struct {
+	Path   string // "-" for stdin
+	Data   []byte
+	Detail string // "low" | "high" | "auto"
+
+	// FileID is set once the image is uploaded.
+	FileID string
+}
+
+// InputAttachment is a local file attached to a message for the assistant's
+// tools to use. It's uploaded before it's sent.
+type InputAttachment struct {
+	Path  string
+	Data  []byte
+	Tools []string // "file_search" | "code_interpreter"
+
+	// FileID is set once the file is uploaded.
+	FileID string
+}
+
 // Implementing the MarshalJSON method for InputText
 func (it *InputText) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]string{
@@ -28,62 +52,274 @@ func (it *InputText) MarshalJSON() ([]byte, error) {
 
 // Implementing the MarshalJSON method for InputImageURL
 func (iu *InputImageURL) MarshalJSON() ([]byte, error) {
+	imageURL := map[string]string{
+		"url": iu.URL.String(),
+	}
+
+	if iu.Detail != "" {
+		imageURL["detail"] = iu.Detail
+	}
+
 	return json.Marshal(map[string]any{
-		"type": "image_url",
-		"image_url": map[string]string{
-			"url": iu.URL.String(),
-			// "detail":    iu.Detail,
-		},
+		"type":      "image_url",
+		"image_url": imageURL,
 	})
 }
 
-func ReadInputFile(path string) (*InputText, error) {
-	// FIXME: handle case where "-"  is used twice?
-	if path == "-" {
-		content, err := io.ReadAll(os.Stdin)
-		if err != nil {
-			return nil, errors.New("error reading from stdin")
+// Implementing the MarshalJSON method for InputImageFile
+func (im *InputImageFile) MarshalJSON() ([]byte, error) {
+	if im.FileID == "" {
+		return nil, fmt.Errorf("image %s is not uploaded", im.Path)
+	}
+
+	imageFile := map[string]string{
+		"file_id": im.FileID,
+	}
+
+	if im.Detail != "" {
+		imageFile["detail"] = im.Detail
+	}
+
+	return json.Marshal(map[string]any{
+		"type":       "image_file",
+		"image_file": imageFile,
+	})
+}
+
+// Name returns the file name to upload the image as.
+func (im *InputImageFile) Name() string {
+	if im.Path != "-" {
+		return filepath.Base(im.Path)
+	}
+
+	// name stdin by its content type, e.g. image/png -> stdin.png
+	contentType := http.DetectContentType(im.Data)
+	ext := strings.TrimPrefix(contentType, "image/")
+	if ext == contentType {
+		ext = "bin"
+	}
+
+	return "stdin." + ext
+}
+
+// Implementing the MarshalJSON method for InputAttachment
+func (ia *InputAttachment) MarshalJSON() ([]byte, error) {
+	if ia.FileID == "" {
+		return nil, fmt.Errorf("attachment %s is not uploaded", ia.Path)
+	}
+
+	var tools []map[string]string
+	for _, tool := range ia.Tools {
+		tools = append(tools, map[string]string{"type": tool})
+	}
+
+	return json.Marshal(map[string]any{
+		"file_id": ia.FileID,
+		"tools":   tools,
+	})
+}
+
+// ParseAttachInput parses a file path, optionally followed by #tool,... to
+// specify the tools that can use the file. Defaults to file_search.
+func ParseAttachInput(spec string) (*InputAttachment, error) {
+	path, toolList, _ := strings.Cut(spec, "#")
+
+	tools := []string{"file_search"}
+	if toolList != "" {
+		tools = strings.Split(toolList, ",")
+	}
+
+	for _, tool := range tools {
+		if tool != "file_search" && tool != "code_interpreter" {
+			return nil, fmt.Errorf("invalid attachment tool: %q (expected file_search or code_interpreter)", tool)
 		}
-		return &InputText{Text: string(content)}, nil
 	}
-	content, err := os.ReadFile(path)
+
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, errors.New("error reading file")
+		return nil, fmt.Errorf("read attachment: %w", err)
 	}
-	return &InputText{Text: string(content)}, nil
+
+	return &InputAttachment{Path: path, Data: data, Tools: tools}, nil
 }
 
-func ParseNakedInput(input string) (json.Marshaler, error) {
-	isStdin := input == "-"
-	if isStdin {
-		return ReadInputFile("-")
+// ParseImageInput parses an image URL or local path, optionally followed by
+// ?detail=low|high|auto.
+func ParseImageInput(spec string) (json.Marshaler, error) {
+	return (&InputParser{Stdin: os.Stdin}).parseImage("image:"+spec, spec)
+}
+
+func (p *InputParser) parseImage(input, spec string) (json.Marshaler, error) {
+	var detail string
+	if i := strings.LastIndex(spec, "?"); i >= 0 {
+		query, err := url.ParseQuery(spec[i+1:])
+		if err == nil && query.Has("detail") {
+			detail = query.Get("detail")
+			query.Del("detail")
+
+			spec = spec[:i]
+			if len(query) > 0 {
+				spec += "?" + query.Encode()
+			}
+		}
 	}
 
-	if _, err := os.Stat(input); err == nil {
-		return ReadInputFile(input)
+	switch detail {
+	case "", "low", "high", "auto":
+	default:
+		return nil, fmt.Errorf("invalid image detail: %q (expected low, high or auto)", detail)
 	}
 
-	return &InputText{Text: input}, nil
+	parsedURL, err := url.Parse(spec)
+	if err == nil && (parsedURL.Scheme == "http" || parsedURL.Scheme == "https" || parsedURL.Scheme == "data") {
+		return &InputImageURL{URL: *parsedURL, Detail: detail}, nil
+	}
+
+	data, err := p.read(input, spec)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+
+	return &InputImageFile{Path: spec, Data: data, Detail: detail}, nil
+}
+
+// ReadInputFile reads a file, or stdin if the path is "-".
+func ReadInputFile(path string) (*InputText, error) {
+	return (&InputParser{Stdin: os.Stdin}).readText("file:"+path, path)
+}
+
+// ParseNakedInput parses an input without a kind: "-" is stdin, an existing
+// path is the file's content, and anything else is text.
+func ParseNakedInput(input string) (json.Marshaler, error) {
+	return (&InputParser{Stdin: os.Stdin}).parseNaked(input)
 }
 
+// ParseInput parses an input of the form kind:spec.
 func ParseInput(input string) (json.Marshaler, error) {
-	parts := strings.SplitN(input, ":", 2)
-	if len(parts) != 2 {
-		return ParseNakedInput(input)
+	return (&InputParser{Stdin: os.Stdin}).Parse(input)
+}
+
+// inputKinds are the kinds of input that Parse recognizes.
+var inputKinds = []string{"text", "image", "file", "attach", "glob", "dir", "git", "tmpl", "pdf", "html", "docx", "as", "meta"}
+
+// IsInputKind reports whether kind is a kind of input, as in kind:spec.
+func IsInputKind(kind string) bool {
+	return slices.Contains(inputKinds, kind)
+}
+
+// InputParser parses the inputs of a command. Stdin is read at most once, and
+// it's an error for more than one input to read it, e.g. `send - file:-`.
+type InputParser struct {
+	Stdin io.Reader
+
+	// Literal treats inputs without a kind as text, rather than guessing
+	// whether they are paths.
+	Literal bool
+
+	// stdinInput is the input that read stdin
+	stdinInput string
+}
+
+// ParseAll parses inputs in order.
+func (p *InputParser) ParseAll(inputs []string) ([]json.Marshaler, error) {
+	var ms []json.Marshaler
+	for _, input := range inputs {
+		m, err := p.Parse(input)
+		if err != nil {
+			return nil, err
+		}
+
+		ms = append(ms, m)
 	}
 
-	switch parts[0] {
+	return ms, nil
+}
+
+// Parse parses an input of the form kind:spec. Inputs of unknown kinds are
+// parsed as naked inputs.
+func (p *InputParser) Parse(input string) (json.Marshaler, error) {
+	kind, spec, ok := strings.Cut(input, ":")
+	if !ok {
+		return p.parseNaked(input)
+	}
+
+	switch kind {
 	case "text":
-		return &InputText{Text: parts[1]}, nil
+		return &InputText{Text: spec}, nil
 	case "image":
-		parsedURL, err := url.Parse(parts[1])
-		if err != nil {
-			return nil, errors.New("invalid URL format")
-		}
-		return &InputImageURL{URL: *parsedURL, Detail: "auto"}, nil
+		return p.parseImage(input, spec)
 	case "file":
-		return ReadInputFile(parts[1])
+		return p.readText(input, spec)
+	case "attach":
+		return ParseAttachInput(spec)
+	case "glob":
+		return ParseGlobInput(spec)
+	case "dir":
+		return ParseDirInput(spec)
+	case "git":
+		return ParseGitInput(spec)
+	case "tmpl":
+		return ParseTemplateInput(spec)
+	case "pdf":
+		return p.parsePDF(input, spec)
+	case "html":
+		return p.parseHTML(input, spec)
+	case "docx":
+		return p.parseDOCX(input, spec)
+	case "as":
+		return ParseRoleInput(spec)
+	case "meta":
+		return ParseMetadataInput(spec)
 	default:
-		return ParseNakedInput(input)
+		return p.parseNaked(input)
+	}
+}
+
+func (p *InputParser) parseNaked(input string) (json.Marshaler, error) {
+	if input == "-" {
+		return p.readText(input, input)
+	}
+
+	if p.Literal {
+		return &InputText{Text: input}, nil
+	}
+
+	if _, err := os.Stat(input); err == nil {
+		return p.readText(input, input)
 	}
+
+	return &InputText{Text: input}, nil
+}
+
+func (p *InputParser) readText(input, path string) (*InputText, error) {
+	content, err := p.read(input, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InputText{Text: string(content)}, nil
+}
+
+// read reads a file, or stdin if the path is "-".
+func (p *InputParser) read(input, path string) ([]byte, error) {
+	if path != "-" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", input, err)
+		}
+
+		return content, nil
+	}
+
+	if p.stdinInput != "" {
+		return nil, fmt.Errorf("%s: stdin was already read by %s", input, p.stdinInput)
+	}
+	p.stdinInput = input
+
+	content, err := io.ReadAll(p.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("read stdin: %w", err)
+	}
+
+	return content, nil
 }
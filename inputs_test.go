@@ -3,6 +3,8 @@ package gpt
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,6 +18,9 @@ func TestParseInput(t *testing.T) {
 	}{
 		{"text:hello world", `{"type":"text","text":"hello world"}`, false},
 		{"image:https://example.com/image.jpg", `{"image_url":{"url":"https://example.com/image.jpg"},"type":"image_url"}`, false},
+		{"image:https://example.com/image.jpg?size=2&detail=high", `{"image_url":{"url":"https://example.com/image.jpg?size=2","detail":"high"},"type":"image_url"}`, false},
+		{"image:https://example.com/image.jpg?detail=huge", ``, true},
+		{"image:testdata/missing.png", ``, true},
 		{"file:testdata/input.md", `{"type":"text","text":"hello from file input\n"}`, false},
 		{"file:-", `{"type":"text","text":"hello from stdin"}`, false},
 		{"naked input", `{"type":"text","text":"naked input"}`, false},
@@ -52,3 +57,164 @@ func TestParseInput(t *testing.T) {
 		assert.JSONEq(tt.expectedJSON, string(actualJSON), "expected JSON %s but got %s for input %s", tt.expectedJSON, string(actualJSON), tt.input)
 	}
 }
+
+func TestParseImageInputFile(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := ParseInput("image:testdata/pixel.png?detail=low")
+	assert.NoError(err)
+
+	image, ok := m.(*InputImageFile)
+	assert.True(ok)
+	assert.Equal("testdata/pixel.png", image.Path)
+	assert.Equal("low", image.Detail)
+	assert.Equal("pixel.png", image.Name())
+
+	// marshalled only once uploaded
+	_, err = json.Marshal(image)
+	assert.Error(err)
+
+	image.FileID = "file-123"
+	actual, err := json.Marshal(image)
+	assert.NoError(err)
+	assert.JSONEq(`{"type":"image_file","image_file":{"file_id":"file-123","detail":"low"}}`, string(actual))
+
+	stdin := &InputImageFile{Path: "-", Data: image.Data}
+	assert.Equal("stdin.png", stdin.Name())
+}
+
+func TestParseAttachInput(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := ParseInput("attach:testdata/input.md#code_interpreter,file_search")
+	assert.NoError(err)
+
+	attachment := m.(*InputAttachment)
+	assert.Equal([]string{"code_interpreter", "file_search"}, attachment.Tools)
+
+	attachment.FileID = "file-123"
+	msg := NewMessage("user", []json.Marshaler{attachment})
+
+	actual, err := json.Marshal(msg)
+	assert.NoError(err)
+	assert.JSONEq(`{
+		"role": "user",
+		"content": [{"type":"text","text":"Attached: input.md"}],
+		"attachments": [{"file_id":"file-123","tools":[{"type":"code_interpreter"},{"type":"file_search"}]}]
+	}`, string(actual))
+
+	_, err = ParseInput("attach:testdata/input.md#web_browser")
+	assert.Error(err)
+}
+
+func TestParseDirInput(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		".git/HEAD":       "ref: refs/heads/main\n",
+		".git/config":     "[remote \"origin\"]\n",
+		".gitignore":      "build/\n*.log\n",
+		"main.go":         "package main\n",
+		"docs/README.md":  "# Docs",
+		"docs/.gitignore": "draft.md\n!keep.log\n",
+		"docs/draft.md":   "wip\n",
+		"docs/keep.log":   "kept\n",
+		"build/out.go":    "package out\n",
+		"debug.log":       "log\n",
+		"data.bin":        "\x00\x01\x02",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(os.WriteFile(path, []byte(content), 0644))
+	}
+
+	m, err := ParseInput("dir:" + dir)
+	assert.NoError(err)
+
+	text := m.(*InputText).Text
+	assert.Equal(
+		"File: "+filepath.ToSlash(filepath.Join(dir, ".gitignore"))+"\n```\nbuild/\n*.log\n```\n\n"+
+			"File: "+filepath.ToSlash(filepath.Join(dir, "docs/.gitignore"))+"\n```\ndraft.md\n!keep.log\n```\n\n"+
+			"File: "+filepath.ToSlash(filepath.Join(dir, "docs/README.md"))+"\n```md\n# Docs\n```\n\n"+
+			// a negation in a deeper .gitignore re-includes the file
+			"File: "+filepath.ToSlash(filepath.Join(dir, "docs/keep.log"))+"\n```\nkept\n```\n\n"+
+			"File: "+filepath.ToSlash(filepath.Join(dir, "main.go"))+"\n```go\npackage main\n```\n",
+		text)
+
+	m, err = ParseInput("glob:" + dir + "/**/*.go")
+	assert.NoError(err)
+	assert.NotContains(m.(*InputText).Text, "out.go")
+	assert.Contains(m.(*InputText).Text, "main.go")
+
+	// the repository itself is never read, even if the pattern matches it
+	m, err = ParseInput("glob:" + dir + "/**/*")
+	assert.NoError(err)
+	assert.NotContains(m.(*InputText).Text, ".git/")
+	assert.NotContains(m.(*InputText).Text, "refs/heads/main")
+	assert.Contains(m.(*InputText).Text, "docs/README.md")
+
+	_, err = ParseInput("glob:" + dir + "/**/*.rs")
+	assert.Error(err)
+}
+
+func TestParseTemplateInput(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := ParseInput("tmpl:testdata/prompts/review.md")
+	assert.NoError(err)
+
+	_, err = json.Marshal(m)
+	assert.Error(err, "not rendered")
+
+	tmpl := m.(*InputTemplate)
+
+	err = tmpl.Render(map[string]any{"team": "infra"})
+	assert.ErrorContains(err, `missing variable "language"`)
+
+	// variables of partials must be given too
+	err = tmpl.Render(map[string]any{"language": "Go"})
+	assert.ErrorContains(err, `missing variable "team"`)
+
+	vars, err := ParseTemplateVars("", []string{"team=infra", "language=Go", "focus=<errors>"})
+	assert.NoError(err)
+
+	err = tmpl.Render(vars)
+	assert.NoError(err)
+	assert.Equal("You are reviewing code for infra.\nReview this Go change, focusing on <errors>.\n", tmpl.Text)
+
+	_, err = ParseTemplateVars("", []string{"team"})
+	assert.Error(err)
+
+	_, err = ParseInput("tmpl:testdata/prompts/missing.md")
+	assert.Error(err)
+}
+
+func TestInputParserStdin(t *testing.T) {
+	assert := assert.New(t)
+
+	parser := &InputParser{Stdin: strings.NewReader("piped")}
+
+	ms, err := parser.ParseAll([]string{"-", "text:hello"})
+	assert.NoError(err)
+	assert.Equal([]json.Marshaler{&InputText{Text: "piped"}, &InputText{Text: "hello"}}, ms)
+
+	_, err = parser.Parse("file:-")
+	assert.EqualError(err, "file:-: stdin was already read by -")
+}
+
+func TestInputParserLiteral(t *testing.T) {
+	assert := assert.New(t)
+
+	parser := &InputParser{Literal: true}
+
+	m, err := parser.Parse("testdata/input.md")
+	assert.NoError(err)
+	assert.Equal(&InputText{Text: "testdata/input.md"}, m)
+
+	// explicit kinds still read files
+	m, err = parser.Parse("file:testdata/input.md")
+	assert.NoError(err)
+	assert.Equal(&InputText{Text: "hello from file input\n"}, m)
+}
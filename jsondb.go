@@ -58,3 +58,10 @@ func (db *JSONDB) Put(key string, value interface{}) error {
 	_, err = db.DB.Exec(query, key, string(jsonValue))
 	return err
 }
+
+// Delete removes a value from the database.
+func (db *JSONDB) Delete(key string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE key = ?", db.TableName)
+	_, err := db.DB.Exec(query, key)
+	return err
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS runs;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE runs DROP COLUMN error_code;
+ALTER TABLE runs DROP COLUMN error_message;
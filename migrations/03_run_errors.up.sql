@@ -0,0 +1,2 @@
+ALTER TABLE runs ADD COLUMN error_code TEXT NOT NULL DEFAULT '';
+ALTER TABLE runs ADD COLUMN error_message TEXT NOT NULL DEFAULT '';
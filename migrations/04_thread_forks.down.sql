@@ -0,0 +1 @@
+DROP TABLE IF EXISTS thread_forks;
\ No newline at end of file
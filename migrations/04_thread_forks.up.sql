@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS thread_forks (
+    thread_id TEXT PRIMARY KEY,
+    parent_thread_id TEXT NOT NULL,
+    parent_message_id TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS thread_forks_parent ON thread_forks (parent_thread_id);
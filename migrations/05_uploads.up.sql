@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS uploads (
+    sha256 TEXT NOT NULL,
+    purpose TEXT NOT NULL,
+    file_id TEXT NOT NULL,
+    filename TEXT NOT NULL,
+    bytes INTEGER NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (sha256, purpose)
+) STRICT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uploads_file_id ON uploads (file_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS prompts;
\ No newline at end of file
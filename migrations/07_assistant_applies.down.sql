@@ -0,0 +1 @@
+DROP TABLE IF EXISTS assistant_applies;
\ No newline at end of file
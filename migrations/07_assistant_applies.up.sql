@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS assistant_applies (
+    key TEXT PRIMARY KEY,
+    assistant_id TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    file TEXT NOT NULL,
+    applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+) STRICT;
@@ -1,20 +1,169 @@
 package gpt
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
 	"github.com/hayeah/goo/fetch"
+	"github.com/tidwall/gjson"
 )
 
 type OpenAIV2API struct {
 	fetch.Options
+
+	secret string
+	client *http.Client
 }
 
-func NewOpenAIV2API(secret string) *OpenAIV2API {
+func NewOpenAIV2API(secret string, client *http.Client) *OpenAIV2API {
 	opts := fetch.Options{
 		BaseURL: "https://api.openai.com/v1",
+		Client:  client,
 	}
 	opts.SetHeader("Content-Type", "application/json")
 	opts.SetHeader("OpenAI-Beta", "assistants=v2")
 	opts.SetHeader("Authorization", "Bearer "+secret)
 
-	return &OpenAIV2API{opts}
+	return &OpenAIV2API{opts, secret, client}
+}
+
+// StatusError is the error of a request that got a non-2xx response.
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Path, e.Status, e.Body)
+}
+
+// isNotFound checks whether err is a 404 response.
+func isNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// do sends a raw request, for the endpoints that don't speak JSON, and for
+// callers that need to check the status of a failed request.
+func (ai *OpenAIV2API) do(method, path string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, ai.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+ai.secret)
+	req.Header.Set("OpenAI-Beta", "assistants=v2")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := ai.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(res.Body)
+		return nil, &StatusError{
+			Method:     method,
+			Path:       path,
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Body:       msg,
+		}
+	}
+
+	return res, nil
+}
+
+// doJSON sends a request with a JSON body, if any, and parses the JSON
+// response. Unlike JSON, a failed request returns a *StatusError.
+func (ai *OpenAIV2API) doJSON(method, path string, body any) (gjson.Result, error) {
+	var reqBody io.Reader
+	var contentType string
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return gjson.Result{}, err
+		}
+
+		reqBody = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	res, err := ai.do(method, path, contentType, reqBody)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	return gjson.ParseBytes(data), nil
+}
+
+// UploadFile uploads a file, and returns the file object.
+func (ai *OpenAIV2API) UploadFile(name string, content io.Reader, purpose string) (gjson.Result, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	err := w.WriteField("purpose", purpose)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	_, err = io.Copy(part, content)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	// https://platform.openai.com/docs/api-reference/files/create
+	// POST https://api.openai.com/v1/files
+	res, err := ai.do("POST", "/files", w.FormDataContentType(), &body)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	return gjson.ParseBytes(data), nil
+}
+
+// DownloadFile returns the content of a file.
+func (ai *OpenAIV2API) DownloadFile(fileID string) ([]byte, error) {
+	// https://platform.openai.com/docs/api-reference/files/retrieve-contents
+	// GET https://api.openai.com/v1/files/{file_id}/content
+	res, err := ai.do("GET", "/files/"+fileID+"/content", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return io.ReadAll(res.Body)
 }
@@ -0,0 +1,186 @@
+package gpt
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SavedPrompt is a send invocation saved under a name. Inputs given when it's
+// run are appended to the saved inputs, and flags given override the saved
+// ones.
+type SavedPrompt struct {
+	Inputs    []string       `json:"inputs,omitempty"`
+	Literal   bool           `json:"literal,omitempty"`
+	Assistant string         `json:"assistant,omitempty"`
+	Tools     string         `json:"tools,omitempty"`
+	Vars      []string       `json:"vars,omitempty"`
+	Options   RunOptionsArgs `json:"options"`
+}
+
+// SendCmd expands the prompt into the equivalent send command.
+func (p *SavedPrompt) SendCmd(cmd SendCmdScope) SendCmdScope {
+	cmd.Inputs = append(append([]string{}, p.Inputs...), cmd.Inputs...)
+	cmd.Literal = cmd.Literal || p.Literal
+
+	if cmd.Assistant == "" {
+		cmd.Assistant = p.Assistant
+	}
+
+	if cmd.Tools == "" {
+		cmd.Tools = p.Tools
+	}
+
+	// later vars win, so the ones given on the command line come last
+	cmd.Vars = append(append([]string{}, p.Vars...), cmd.Vars...)
+
+	cmd.RunOptionsArgs = p.Options.Override(cmd.RunOptionsArgs)
+
+	return cmd
+}
+
+// PromptManager saves and runs named prompts.
+type PromptManager struct {
+	TR *ThreadRunner
+
+	db *sqlx.DB
+}
+
+// Save saves a prompt, replacing any prompt of the same name.
+func (pm *PromptManager) Save(cmd PromptSaveCmd) error {
+	_, err := ParseTemplateVars("", cmd.Vars)
+	if err != nil {
+		return err
+	}
+
+	prompt := &SavedPrompt{
+		Inputs:    cmd.Inputs,
+		Literal:   cmd.Literal,
+		Assistant: cmd.Assistant,
+		Tools:     cmd.Tools,
+		Vars:      cmd.Vars,
+		Options:   cmd.RunOptionsArgs,
+	}
+
+	data, err := json.Marshal(prompt)
+	if err != nil {
+		return err
+	}
+
+	_, err = pm.db.Exec(`
+		INSERT INTO prompts (name, prompt) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET prompt = excluded.prompt, updated_at = CURRENT_TIMESTAMP
+	`, cmd.Name, string(data))
+	if err != nil {
+		return fmt.Errorf("save prompt: %w", err)
+	}
+
+	fmt.Printf("saved prompt %s\n", cmd.Name)
+
+	return nil
+}
+
+// Get loads a saved prompt.
+func (pm *PromptManager) Get(name string) (*SavedPrompt, error) {
+	var data string
+	err := pm.db.Get(&data, "SELECT prompt FROM prompts WHERE name = ?", name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("prompt %s not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var prompt SavedPrompt
+	err = json.Unmarshal([]byte(data), &prompt)
+	if err != nil {
+		return nil, fmt.Errorf("prompt %s: %w", name, err)
+	}
+
+	return &prompt, nil
+}
+
+// List prints the saved prompts.
+func (pm *PromptManager) List() error {
+	var rows []struct {
+		Name      string `db:"name"`
+		Prompt    string `db:"prompt"`
+		UpdatedAt string `db:"updated_at"`
+	}
+
+	err := pm.db.Select(&rows, "SELECT name, prompt, updated_at FROM prompts ORDER BY name")
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tASSISTANT\tINPUTS\tUPDATED")
+	for _, row := range rows {
+		var prompt SavedPrompt
+		err = json.Unmarshal([]byte(row.Prompt), &prompt)
+		if err != nil {
+			return fmt.Errorf("prompt %s: %w", row.Name, err)
+		}
+
+		assistant := prompt.Assistant
+		if assistant == "" {
+			assistant = "(current)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row.Name, assistant, strings.Join(prompt.Inputs, " "), row.UpdatedAt)
+	}
+
+	return w.Flush()
+}
+
+// Show prints a saved prompt.
+func (pm *PromptManager) Show(name string) error {
+	prompt, err := pm.Get(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(prompt, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+
+	return nil
+}
+
+// Remove deletes a saved prompt.
+func (pm *PromptManager) Remove(name string) error {
+	res, err := pm.db.Exec("DELETE FROM prompts WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return fmt.Errorf("prompt %s not found", name)
+	}
+
+	return nil
+}
+
+// Run sends a saved prompt, as if by `gpt send`.
+func (pm *PromptManager) Run(cmd PromptRunCmd) error {
+	prompt, err := pm.Get(cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	return pm.TR.RunStream(prompt.SendCmd(cmd.SendCmdScope))
+}
@@ -0,0 +1,39 @@
+package gpt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavedPromptSendCmd(t *testing.T) {
+	assert := assert.New(t)
+
+	temperature := 0.2
+	prompt := &SavedPrompt{
+		Inputs:    []string{"tmpl:prompts/review.md"},
+		Assistant: "asst_review",
+		Vars:      []string{"language=Go", "focus=errors"},
+		Options:   RunOptionsArgs{Model: "gpt-4o", Temperature: &temperature},
+	}
+
+	cmd := prompt.SendCmd(SendCmdScope{
+		Inputs:         []string{"file:x.go"},
+		ContinueThread: true,
+		Vars:           []string{"focus=naming"},
+		RunOptionsArgs: RunOptionsArgs{Model: "gpt-4o-mini"},
+	})
+
+	assert.Equal([]string{"tmpl:prompts/review.md", "file:x.go"}, cmd.Inputs)
+	assert.Equal("asst_review", cmd.Assistant)
+	assert.True(cmd.ContinueThread)
+	assert.Equal("gpt-4o-mini", cmd.Model)
+	assert.Equal(&temperature, cmd.Temperature)
+
+	vars, err := ParseTemplateVars("", cmd.Vars)
+	assert.NoError(err)
+	assert.Equal(map[string]any{"language": "Go", "focus": "naming"}, vars)
+
+	// the saved prompt is not modified
+	assert.Equal([]string{"tmpl:prompts/review.md"}, prompt.Inputs)
+}
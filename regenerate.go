@@ -0,0 +1,83 @@
+package gpt
+
+import (
+	"fmt"
+
+	"github.com/hayeah/goo/fetch"
+)
+
+// Retry deletes the assistant's last reply in the current thread, and runs
+// the thread again to regenerate it.
+func (tr *ThreadRunner) Retry(cmd RetryCmd) error {
+	err := tr.checkBudget(cmd.Force)
+	if err != nil {
+		return err
+	}
+
+	threadID, err := tr.appDB.CurrentThreadID()
+	if err != nil {
+		return err
+	}
+
+	if threadID == "" {
+		return fmt.Errorf("retry: no current thread")
+	}
+
+	assistantID := cmd.Assistant
+	if assistantID == "" {
+		assistantID, err = tr.AM.CurrentAssistantID()
+		if err != nil {
+			return err
+		}
+	}
+
+	opts, err := cmd.RunOptions()
+	if err != nil {
+		return err
+	}
+
+	messages, _, err := tr.Threads.listMessagesPage(threadID, "desc", "")
+	if err != nil {
+		return err
+	}
+
+	// the reply is every assistant message after the last user message
+	var deleted int
+	for _, message := range messages {
+		if message.Get("role").String() != "assistant" {
+			break
+		}
+
+		// https://platform.openai.com/docs/api-reference/messages/deleteMessage
+		// DELETE https://api.openai.com/v1/threads/{thread_id}/messages/{message_id}
+		_, err = tr.oai.JSON("DELETE", "/threads/{{thread_id}}/messages/{{message_id}}", &fetch.Options{
+			PathParams: map[string]string{
+				"thread_id":  threadID,
+				"message_id": message.Get("id").String(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		deleted++
+	}
+
+	if deleted == 0 && len(messages) == 0 {
+		return fmt.Errorf("retry: thread %s has no messages", threadID)
+	}
+
+	tr.log.Info("Retry", "thread", threadID, "deleted", deleted)
+
+	out, flush := markdownWriter(cmd.Markdown)
+	defer flush()
+
+	_, err = tr.Run(RunParams{
+		AssistantID: assistantID,
+		ThreadID:    threadID,
+		Options:     opts,
+		Tools:       cmd.Tools,
+	}, out)
+
+	return err
+}
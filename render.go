@@ -0,0 +1,290 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"golang.org/x/term"
+)
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiCyan      = "\x1b[36m"
+	ansiMagenta   = "\x1b[35m"
+	ansiYellow    = "\x1b[33m"
+)
+
+var (
+	mdHeading   = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdBullet    = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdOrdered   = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdQuote     = regexp.MustCompile(`^>\s?(.*)$`)
+	mdRule      = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	mdFence     = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	mdTableSep  = regexp.MustCompile(`^\s*\|?(\s*:?-+:?\s*\|)+\s*:?-*:?\s*$`)
+	mdCode      = regexp.MustCompile("`([^`]+)`")
+	mdBold      = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalic    = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_\s][^_]*)_\b`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdCodeStash = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// MarkdownRenderer incrementally formats streamed markdown for a terminal.
+//
+// Deltas are buffered until a full line is available, so the output lags the
+// stream by at most one line. Table rows are held back until the table ends so
+// that columns can be aligned.
+type MarkdownRenderer struct {
+	w   io.Writer
+	buf strings.Builder
+
+	fence string // the opening fence marker while inside a code block
+	lexer chroma.Lexer
+	table []string
+}
+
+// NewMarkdownRenderer creates a renderer that writes ANSI formatted output to w.
+func NewMarkdownRenderer(w io.Writer) *MarkdownRenderer {
+	return &MarkdownRenderer{w: w}
+}
+
+// markdownWriter returns stdout, wrapped in a MarkdownRenderer if enabled and
+// stdout is a terminal. Call flush once the reply is written.
+func markdownWriter(enabled bool) (out io.Writer, flush func()) {
+	if !enabled || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return os.Stdout, func() {}
+	}
+
+	md := NewMarkdownRenderer(os.Stdout)
+	return md, func() { md.Flush() }
+}
+
+// Write buffers streamed text, and renders every completed line.
+func (r *MarkdownRenderer) Write(p []byte) (int, error) {
+	r.buf.Write(p)
+
+	s := r.buf.String()
+	i := strings.LastIndexByte(s, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+
+	r.buf.Reset()
+	r.buf.WriteString(s[i+1:])
+
+	for _, line := range strings.Split(s[:i], "\n") {
+		err := r.renderLine(line)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush renders any buffered partial line and pending table.
+func (r *MarkdownRenderer) Flush() error {
+	if r.buf.Len() > 0 {
+		line := r.buf.String()
+		r.buf.Reset()
+
+		err := r.renderLine(line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.flushTable()
+}
+
+func (r *MarkdownRenderer) renderLine(line string) error {
+	if r.fence != "" {
+		return r.renderCodeLine(line)
+	}
+
+	isTableRow := strings.HasPrefix(strings.TrimSpace(line), "|")
+	if isTableRow {
+		r.table = append(r.table, line)
+		return nil
+	}
+
+	err := r.flushTable()
+	if err != nil {
+		return err
+	}
+
+	var out string
+	switch {
+	case mdFence.MatchString(line):
+		m := mdFence.FindStringSubmatch(line)
+		r.fence = m[1]
+		r.lexer = codeLexer(m[2])
+		out = ansiDim + line + ansiReset
+	case mdHeading.MatchString(line):
+		m := mdHeading.FindStringSubmatch(line)
+		out = ansiBold + ansiUnderline + ansiMagenta + renderInline(m[2]) + ansiReset
+	case mdRule.MatchString(line):
+		out = ansiDim + strings.Repeat("─", 40) + ansiReset
+	case mdBullet.MatchString(line):
+		m := mdBullet.FindStringSubmatch(line)
+		out = m[1] + ansiYellow + "•" + ansiReset + " " + renderInline(m[2])
+	case mdOrdered.MatchString(line):
+		m := mdOrdered.FindStringSubmatch(line)
+		out = m[1] + ansiYellow + m[2] + ansiReset + " " + renderInline(m[3])
+	case mdQuote.MatchString(line):
+		m := mdQuote.FindStringSubmatch(line)
+		out = ansiDim + "│ " + ansiReset + ansiItalic + renderInline(m[1]) + ansiReset
+	default:
+		out = renderInline(line)
+	}
+
+	_, err = fmt.Fprintln(r.w, out)
+	return err
+}
+
+func (r *MarkdownRenderer) renderCodeLine(line string) error {
+	if strings.HasPrefix(strings.TrimSpace(line), r.fence) {
+		r.fence = ""
+		r.lexer = nil
+		_, err := fmt.Fprintln(r.w, ansiDim+line+ansiReset)
+		return err
+	}
+
+	// NB: each line is tokenized on its own, so constructs spanning several
+	// lines (e.g. block comments) are not highlighted accurately. The trade-off
+	// is that code is shown as soon as each line arrives.
+	it, err := r.lexer.Tokenise(nil, line+"\n")
+	if err != nil {
+		_, err = fmt.Fprintln(r.w, line)
+		return err
+	}
+
+	return formatters.TTY256.Format(r.w, styles.Get("monokai"), it)
+}
+
+func (r *MarkdownRenderer) flushTable() error {
+	if len(r.table) == 0 {
+		return nil
+	}
+
+	rows := r.table
+	r.table = nil
+
+	var cells [][]string
+	var widths []int
+	for _, row := range rows {
+		if mdTableSep.MatchString(row) {
+			cells = append(cells, nil)
+			continue
+		}
+
+		row = strings.TrimSpace(row)
+		row = strings.TrimPrefix(row, "|")
+		row = strings.TrimSuffix(row, "|")
+
+		var cols []string
+		for i, col := range strings.Split(row, "|") {
+			col = renderInline(strings.TrimSpace(col))
+			cols = append(cols, col)
+
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], visibleWidth(col))
+		}
+		cells = append(cells, cols)
+	}
+
+	for i, cols := range cells {
+		var line strings.Builder
+
+		if cols == nil {
+			for j, width := range widths {
+				if j > 0 {
+					line.WriteString("─┼─")
+				}
+				line.WriteString(strings.Repeat("─", width))
+			}
+			_, err := fmt.Fprintln(r.w, ansiDim+line.String()+ansiReset)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for j, width := range widths {
+			if j > 0 {
+				line.WriteString(ansiDim + " │ " + ansiReset)
+			}
+
+			var col string
+			if j < len(cols) {
+				col = cols[j]
+			}
+			pad := width - visibleWidth(col)
+
+			// the row before the separator is the header
+			isHeader := i+1 < len(cells) && cells[i+1] == nil
+			if isHeader {
+				col = ansiBold + col + ansiReset
+			}
+
+			line.WriteString(col)
+			line.WriteString(strings.Repeat(" ", pad))
+		}
+
+		_, err := fmt.Fprintln(r.w, strings.TrimRight(line.String(), " "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderInline formats code spans, links, bold and italic text within a line.
+func renderInline(s string) string {
+	// stash code spans so their content is not formatted as emphasis
+	var codes []string
+	s = mdCode.ReplaceAllStringFunc(s, func(m string) string {
+		codes = append(codes, ansiCyan+mdCode.FindStringSubmatch(m)[1]+ansiReset)
+		return fmt.Sprintf("\x00%d\x00", len(codes)-1)
+	})
+
+	s = mdLink.ReplaceAllString(s, ansiUnderline+"$1"+ansiReset+" "+ansiDim+"($2)"+ansiReset)
+	s = mdBold.ReplaceAllString(s, ansiBold+"$1$2"+ansiReset)
+	s = mdItalic.ReplaceAllString(s, ansiItalic+"$1$2"+ansiReset)
+
+	return mdCodeStash.ReplaceAllStringFunc(s, func(m string) string {
+		var i int
+		fmt.Sscanf(strings.Trim(m, "\x00"), "%d", &i)
+		return codes[i]
+	})
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// visibleWidth returns the display width of a cell, ignoring ANSI escapes.
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(s, ""))
+}
+
+func codeLexer(lang string) chroma.Lexer {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return chroma.Coalesce(lexer)
+}
@@ -0,0 +1,26 @@
+package gpt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownRenderer(t *testing.T) {
+	assert := assert.New(t)
+
+	var out strings.Builder
+	md := NewMarkdownRenderer(&out)
+
+	// deltas split mid-line are held until the line completes
+	md.Write([]byte("# Ti"))
+	assert.Empty(out.String())
+	md.Write([]byte("tle\n- **bold** and `co*de*`\n"))
+	md.Write([]byte("| a | bb |\n|---|---|\n| ccc | d |\n"))
+	md.Write([]byte("```go\nfunc main() {}\n```\ntail"))
+	assert.NoError(md.Flush())
+
+	plain := ansiEscape.ReplaceAllString(out.String(), "")
+	assert.Equal("Title\n• bold and co*de*\na   │ bb\n────┼───\nccc │ d\n```go\nfunc main() {}\n```\ntail\n", plain)
+}
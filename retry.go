@@ -0,0 +1,237 @@
+package gpt
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first. Defaults to 5.
+	MaxAttempts int
+	// BaseDelay is the backoff of the first retry, doubled on each retry. Defaults to 500ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts. Defaults to 60s.
+	MaxDelay time.Duration
+}
+
+// RetryTransport retries requests that failed with rate limits or transient
+// server errors, with jittered exponential backoff.
+//
+// Requests that are not idempotent (i.e. POST) are only retried on 429, which
+// the server returns without processing the request, so that a retry never
+// creates a second run, thread, or tool output submission. A 503 may come from
+// a proxy after the request was processed. Idempotent requests are also
+// retried on 5xx responses and network errors.
+type RetryTransport struct {
+	Transport http.RoundTripper
+	Config    RetryConfig
+	Log       *slog.Logger
+
+	// sleep is replaced in tests
+	sleep func(req *http.Request, d time.Duration) error
+}
+
+// NewRetryTransport wraps the default transport.
+func NewRetryTransport(cfg RetryConfig, log *slog.Logger) *RetryTransport {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 500 * time.Millisecond
+	}
+
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 60 * time.Second
+	}
+
+	return &RetryTransport{
+		Transport: http.DefaultTransport,
+		Config:    cfg,
+		Log:       log,
+	}
+}
+
+// RoundTrip sends a clone of the request on each attempt, since a
+// RoundTripper must not modify the request it's given.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	getBody := req.GetBody
+	if req.Body != nil && getBody == nil {
+		// buffer the body so it can be replayed
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		getBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	} else if req.Body != nil {
+		// the first attempt reads a body from GetBody like the others
+		req.Body.Close()
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req.Clone(req.Context())
+		if req.Body != nil {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+
+			attemptReq.Body = body
+			attemptReq.GetBody = getBody
+		}
+
+		res, err := t.Transport.RoundTrip(attemptReq)
+
+		if attempt >= t.Config.MaxAttempts || !t.shouldRetry(req, res, err) {
+			return res, err
+		}
+
+		delay := t.backoff(attempt, res)
+
+		if t.Log != nil {
+			status := 0
+			if res != nil {
+				status = res.StatusCode
+			}
+
+			t.Log.Warn("retrying request",
+				"method", req.Method, "url", req.URL.Path, "status", status, "err", err,
+				"attempt", attempt, "delay", delay)
+		}
+
+		if res != nil {
+			// drain so that the connection can be reused
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		err = t.wait(req, delay)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *RetryTransport) shouldRetry(req *http.Request, res *http.Response, err error) bool {
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead ||
+		req.Method == http.MethodDelete || req.Method == http.MethodOptions
+
+	if err != nil {
+		// the request may have reached the server
+		return idempotent && req.Context().Err() == nil
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		// 429 for quota errors are not transient
+		return !isQuotaExceeded(res)
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	default:
+		return false
+	}
+}
+
+// isQuotaExceeded checks whether a 429 is due to an exhausted billing quota,
+// which retrying cannot fix.
+func isQuotaExceeded(res *http.Response) bool {
+	if res.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	return bytes.Contains(body, []byte("insufficient_quota"))
+}
+
+// backoff returns the delay before the next attempt. The server's hint is used
+// if the response has one, otherwise it's a jittered exponential backoff.
+func (t *RetryTransport) backoff(attempt int, res *http.Response) time.Duration {
+	delay, ok := retryAfter(res)
+	if !ok {
+		// full jitter: https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+		ceiling := t.Config.BaseDelay << (attempt - 1)
+		if ceiling <= 0 || ceiling > t.Config.MaxDelay {
+			ceiling = t.Config.MaxDelay
+		}
+		delay = time.Duration(rand.Int63n(int64(ceiling)) + 1)
+	}
+
+	return min(delay, t.Config.MaxDelay)
+}
+
+// retryAfter reads the delay the server asked for, from the retry-after-ms,
+// retry-after, or x-ratelimit-reset-* headers.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	h := res.Header
+
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(secs * float64(time.Second)), true
+		}
+
+		if at, err := http.ParseTime(v); err == nil {
+			return max(time.Until(at), 0), true
+		}
+	}
+
+	// e.g. x-ratelimit-reset-requests: 1s, x-ratelimit-reset-tokens: 6m0s
+	var reset time.Duration
+	var ok bool
+	for _, name := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		d, err := time.ParseDuration(strings.TrimSpace(h.Get(name)))
+		if err != nil {
+			continue
+		}
+
+		// only wait for the limit that was actually hit
+		remaining := h.Get(strings.Replace(name, "Reset", "Remaining", 1))
+		if remaining != "" && remaining != "0" {
+			continue
+		}
+
+		reset = max(reset, d)
+		ok = true
+	}
+
+	return reset, ok
+}
+
+func (t *RetryTransport) wait(req *http.Request, d time.Duration) error {
+	if t.sleep != nil {
+		return t.sleep(req, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -0,0 +1,121 @@
+package gpt
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer responds with the given statuses in order, then 200.
+func fakeServer(t *testing.T, statuses []int, header http.Header) (*httptest.Server, *[]string) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		if len(bodies) <= len(statuses) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(statuses[len(bodies)-1])
+			return
+		}
+
+		w.Write([]byte(`{"id":"ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &bodies
+}
+
+func testRetryClient(delays *[]time.Duration) *http.Client {
+	rt := NewRetryTransport(RetryConfig{MaxAttempts: 3}, nil)
+	rt.sleep = func(req *http.Request, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+	return &http.Client{Transport: rt}
+}
+
+func TestRetryTransport(t *testing.T) {
+	t.Run("retries rate limited POST with its body, honoring Retry-After", func(t *testing.T) {
+		assert := assert.New(t)
+
+		srv, bodies := fakeServer(t, []int{429}, http.Header{"Retry-After": {"2"}})
+
+		var delays []time.Duration
+		res, err := testRetryClient(&delays).Post(srv.URL, "application/json", strings.NewReader(`{"a":1}`))
+		assert.NoError(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal([]string{`{"a":1}`, `{"a":1}`}, *bodies)
+		assert.Equal([]time.Duration{2 * time.Second}, delays)
+	})
+
+	t.Run("uses the rate limit reset of the exhausted limit", func(t *testing.T) {
+		assert := assert.New(t)
+
+		srv, _ := fakeServer(t, []int{429}, http.Header{
+			"X-Ratelimit-Remaining-Requests": {"10"},
+			"X-Ratelimit-Reset-Requests":     {"1s"},
+			"X-Ratelimit-Remaining-Tokens":   {"0"},
+			"X-Ratelimit-Reset-Tokens":       {"6m0s"},
+		})
+
+		var delays []time.Duration
+		_, err := testRetryClient(&delays).Get(srv.URL)
+		assert.NoError(err)
+		assert.Equal([]time.Duration{60 * time.Second}, delays, "capped at MaxDelay")
+	})
+
+	t.Run("does not retry POST on 5xx", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for _, status := range []int{500, 503} {
+			srv, bodies := fakeServer(t, []int{status}, nil)
+
+			var delays []time.Duration
+			res, err := testRetryClient(&delays).Post(srv.URL, "application/json", strings.NewReader(`{}`))
+			assert.NoError(err)
+			assert.Equal(status, res.StatusCode)
+			assert.Len(*bodies, 1)
+		}
+	})
+
+	t.Run("retries GET on 5xx up to MaxAttempts", func(t *testing.T) {
+		assert := assert.New(t)
+
+		srv, bodies := fakeServer(t, []int{500, 503, 504}, nil)
+
+		var delays []time.Duration
+		res, err := testRetryClient(&delays).Get(srv.URL)
+		assert.NoError(err)
+		assert.Equal(504, res.StatusCode)
+		assert.Len(*bodies, 3)
+		for i, d := range delays {
+			assert.LessOrEqual(d, 500*time.Millisecond<<i)
+		}
+	})
+
+	t.Run("does not modify the request", func(t *testing.T) {
+		assert := assert.New(t)
+
+		srv, bodies := fakeServer(t, []int{429}, nil)
+
+		req, err := http.NewRequest("POST", srv.URL, io.NopCloser(strings.NewReader(`{"b":2}`)))
+		assert.NoError(err)
+		body := req.Body
+
+		var delays []time.Duration
+		res, err := testRetryClient(&delays).Transport.RoundTrip(req)
+		assert.NoError(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal([]string{`{"b":2}`, `{"b":2}`}, *bodies)
+		assert.Equal(body, req.Body)
+		assert.Nil(req.GetBody)
+	})
+}
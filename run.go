@@ -77,3 +77,66 @@ func (rm *RunManager) ListSteps() error {
 
 	return nil
 }
+
+// RunError is returned when a run ends without completing.
+//
+// Each status maps to a distinct process exit code:
+//
+//	failed      3
+//	incomplete  4
+//	expired     5
+//	cancelled   6
+//	error       7 (the stream reported an error)
+type RunError struct {
+	RunID   string
+	Status  string
+	Code    string
+	Message string
+}
+
+func (e *RunError) Error() string {
+	msg := fmt.Sprintf("run %s", e.Status)
+	if e.RunID != "" {
+		msg = fmt.Sprintf("run %s %s", e.RunID, e.Status)
+	}
+
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	return msg
+}
+
+// ExitCode returns the process exit code for the run status.
+func (e *RunError) ExitCode() int {
+	switch e.Status {
+	case "failed":
+		return 3
+	case "incomplete":
+		return 4
+	case "expired":
+		return 5
+	case "cancelled":
+		return 6
+	default:
+		return 7
+	}
+}
+
+// Cancel cancels a run that is in progress.
+func (rm *RunManager) Cancel(threadID, runID string) error {
+	// https://platform.openai.com/docs/api-reference/runs/cancelRun
+	// POST https://api.openai.com/v1/threads/{thread_id}/runs/{run_id}/cancel
+	_, err := rm.ai.JSON("POST", "/threads/{{ThreadID}}/runs/{{RunID}}/cancel", &fetch.Options{
+		PathParams: &ThreadRunParams{
+			ThreadID: threadID,
+			RunID:    runID,
+		},
+	})
+
+	return err
+}
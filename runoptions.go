@@ -0,0 +1,158 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RunOptions overrides the assistant's settings for a single run.
+//
+// https://platform.openai.com/docs/api-reference/runs/createRun
+type RunOptions struct {
+	Model                  string   `json:"model,omitempty"`
+	Instructions           string   `json:"instructions,omitempty"`
+	AdditionalInstructions string   `json:"additional_instructions,omitempty"`
+	Temperature            *float64 `json:"temperature,omitempty"`
+	TopP                   *float64 `json:"top_p,omitempty"`
+	ToolChoice             any      `json:"tool_choice,omitempty"`
+	ParallelToolCalls      *bool    `json:"parallel_tool_calls,omitempty"`
+	MaxPromptTokens        int      `json:"max_prompt_tokens,omitempty"`
+	MaxCompletionTokens    int      `json:"max_completion_tokens,omitempty"`
+	TruncationStrategy     any      `json:"truncation_strategy,omitempty"`
+	ResponseFormat         any      `json:"response_format,omitempty"`
+}
+
+// Message is a message to add to a thread.
+type Message struct {
+	Role        string            `json:"role"`
+	Content     []json.Marshaler  `json:"content"`
+	Attachments []json.Marshaler  `json:"attachments,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// NewMessage creates a message from parsed inputs, separating the file
+// attachments from the content.
+func NewMessage(role string, inputs []json.Marshaler) Message {
+	m := Message{Role: role}
+
+	var names []string
+	for _, input := range inputs {
+		if attachment, ok := input.(*InputAttachment); ok {
+			m.Attachments = append(m.Attachments, attachment)
+			names = append(names, filepath.Base(attachment.Path))
+			continue
+		}
+
+		m.Content = append(m.Content, input)
+	}
+
+	// content is required
+	if len(m.Content) == 0 && len(names) > 0 {
+		m.Content = append(m.Content, &InputText{Text: "Attached: " + strings.Join(names, ", ")})
+	}
+
+	return m
+}
+
+// RunRequest is the body of the create run, and create thread and run requests.
+type RunRequest struct {
+	AssistantID string `json:"assistant_id"`
+
+	// Thread is used to create a new thread.
+	Thread *ThreadRequest `json:"thread,omitempty"`
+	// AdditionalMessages are appended to an existing thread.
+	AdditionalMessages []Message `json:"additional_messages,omitempty"`
+
+	Stream bool `json:"stream"`
+
+	*RunOptions
+}
+
+type ThreadRequest struct {
+	Messages []Message `json:"messages"`
+}
+
+// RunOptions converts the command line overrides to run options.
+func (a *RunOptionsArgs) RunOptions() (*RunOptions, error) {
+	opts := &RunOptions{
+		Model:                  a.Model,
+		Instructions:           a.Instructions,
+		AdditionalInstructions: a.AdditionalInstructions,
+		Temperature:            a.Temperature,
+		TopP:                   a.TopP,
+		ParallelToolCalls:      a.ParallelToolCalls,
+		MaxPromptTokens:        a.MaxPromptTokens,
+		MaxCompletionTokens:    a.MaxCompletionTokens,
+	}
+
+	// https://platform.openai.com/docs/api-reference/runs/createRun#runs-createrun-tool_choice
+	switch a.ToolChoice {
+	case "":
+	case "none", "auto", "required":
+		opts.ToolChoice = a.ToolChoice
+	case "file_search", "code_interpreter":
+		opts.ToolChoice = map[string]string{"type": a.ToolChoice}
+	default:
+		opts.ToolChoice = map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": a.ToolChoice},
+		}
+	}
+
+	// https://platform.openai.com/docs/api-reference/runs/createRun#runs-createrun-truncation_strategy
+	switch {
+	case a.Truncation == "":
+	case a.Truncation == "auto":
+		opts.TruncationStrategy = map[string]string{"type": "auto"}
+	case strings.HasPrefix(a.Truncation, "last_messages:"):
+		n, err := strconv.Atoi(strings.TrimPrefix(a.Truncation, "last_messages:"))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid truncation: %q", a.Truncation)
+		}
+
+		opts.TruncationStrategy = map[string]any{"type": "last_messages", "last_messages": n}
+	default:
+		return nil, fmt.Errorf("invalid truncation: %q (expected auto or last_messages:N)", a.Truncation)
+	}
+
+	return opts, nil
+}
+
+// Override returns the options with the fields set in o replacing those of a.
+func (a RunOptionsArgs) Override(o RunOptionsArgs) RunOptionsArgs {
+	if o.Model != "" {
+		a.Model = o.Model
+	}
+	if o.Instructions != "" {
+		a.Instructions = o.Instructions
+	}
+	if o.AdditionalInstructions != "" {
+		a.AdditionalInstructions = o.AdditionalInstructions
+	}
+	if o.Temperature != nil {
+		a.Temperature = o.Temperature
+	}
+	if o.TopP != nil {
+		a.TopP = o.TopP
+	}
+	if o.ToolChoice != "" {
+		a.ToolChoice = o.ToolChoice
+	}
+	if o.ParallelToolCalls != nil {
+		a.ParallelToolCalls = o.ParallelToolCalls
+	}
+	if o.MaxPromptTokens != 0 {
+		a.MaxPromptTokens = o.MaxPromptTokens
+	}
+	if o.MaxCompletionTokens != 0 {
+		a.MaxCompletionTokens = o.MaxCompletionTokens
+	}
+	if o.Truncation != "" {
+		a.Truncation = o.Truncation
+	}
+
+	return a
+}
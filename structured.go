@@ -0,0 +1,141 @@
+package gpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// JSONSchemaFormat is a json_schema response_format.
+//
+// https://platform.openai.com/docs/api-reference/runs/createRun#runs-createrun-response_format
+type JSONSchemaFormat struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict,omitempty"`
+}
+
+var invalidSchemaName = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// ResponseSchema is a JSON schema the assistant's reply must conform to.
+type ResponseSchema struct {
+	Format JSONSchemaFormat
+	schema *jsonschema.Schema
+}
+
+// LoadResponseSchema reads and compiles a JSON schema file.
+func LoadResponseSchema(file string) (*ResponseSchema, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+
+	schema, err := jsonschema.CompileString(file, string(data))
+	if err != nil {
+		return nil, fmt.Errorf("compile schema: %w", err)
+	}
+
+	// the schema name must match ^[a-zA-Z0-9_-]+$
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	name = invalidSchemaName.ReplaceAllString(name, "_")
+
+	return &ResponseSchema{
+		Format: JSONSchemaFormat{Name: name, Schema: data},
+		schema: schema,
+	}, nil
+}
+
+// ResponseFormat returns the response_format parameter of a run.
+func (rs *ResponseSchema) ResponseFormat() any {
+	return map[string]any{
+		"type":        "json_schema",
+		"json_schema": rs.Format,
+	}
+}
+
+// Validate parses a reply as JSON, and validates it against the schema.
+func (rs *ResponseSchema) Validate(reply string) (json.RawMessage, error) {
+	dec := json.NewDecoder(strings.NewReader(reply))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, fmt.Errorf("reply is not valid JSON: %w", err)
+	}
+
+	// a reply is a single JSON value, with nothing after it
+	_, err = dec.Token()
+	if !errors.Is(err, io.EOF) {
+		return nil, errors.New("reply is not valid JSON: unexpected data after the JSON value")
+	}
+
+	err = rs.schema.Validate(v)
+	if err != nil {
+		// GoString lists the cause of every failed keyword
+		return nil, fmt.Errorf("%#v", err)
+	}
+
+	return json.RawMessage(reply), nil
+}
+
+// RunStructured runs with the reply constrained to a JSON schema, and prints
+// only the validated JSON. If the reply does not validate, the assistant is
+// asked to correct it up to the given number of retries.
+func (tr *ThreadRunner) RunStructured(params RunParams, schemaFile string, retries int) error {
+	rs, err := LoadResponseSchema(schemaFile)
+	if err != nil {
+		return err
+	}
+
+	if params.Options == nil {
+		params.Options = &RunOptions{}
+	}
+	params.Options.ResponseFormat = rs.ResponseFormat()
+
+	for attempt := 0; ; attempt++ {
+		reply, err := tr.Run(params, io.Discard)
+		if err != nil {
+			return err
+		}
+
+		result, verr := rs.Validate(reply)
+		if verr == nil {
+			var buf bytes.Buffer
+			err = json.Indent(&buf, result, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(buf.String())
+			return nil
+		}
+
+		if attempt >= retries {
+			return fmt.Errorf("schema validation: %w", verr)
+		}
+
+		tr.log.Warn("reply does not match schema, retrying", "attempt", attempt+1, "err", verr)
+
+		// continue on the thread the first attempt created
+		params.ThreadID, err = tr.appDB.CurrentThreadID()
+		if err != nil {
+			return err
+		}
+
+		params.Messages = []Message{{
+			Role: "user",
+			Content: []json.Marshaler{&InputText{
+				Text: fmt.Sprintf("The reply does not validate against the JSON schema:\n\n%s\n\nReply again with only the corrected JSON.", verr),
+			}},
+		}}
+	}
+}
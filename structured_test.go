@@ -0,0 +1,34 @@
+package gpt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	rs, err := LoadResponseSchema("testdata/person.schema.json")
+	assert.NoError(err)
+	assert.Equal("person_schema", rs.Format.Name)
+
+	_, err = rs.Validate(`{"name": "Ada", "age": 36}`)
+	assert.NoError(err)
+
+	_, err = rs.Validate(`{"name": "Ada", "age": "36"}`)
+	assert.ErrorContains(err, "age")
+
+	_, err = rs.Validate("Sure! Here is the JSON")
+	assert.ErrorContains(err, "not valid JSON")
+
+	// trailing text would fail to indent later, so it fails validation
+	_, err = rs.Validate(`{"name": "Ada", "age": 36} Hope this helps!`)
+	assert.ErrorContains(err, "not valid JSON")
+
+	_, err = rs.Validate(`{"name": "Ada", "age": 36}{"name": "Bob", "age": 40}`)
+	assert.ErrorContains(err, "not valid JSON")
+
+	_, err = rs.Validate("{\"name\": \"Ada\", \"age\": 36}\n")
+	assert.NoError(err)
+}
@@ -0,0 +1,180 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/runZeroInc/mustache/v2"
+)
+
+// InputTemplate is a mustache template, which is rendered with the template
+// variables before it's sent.
+type InputTemplate struct {
+	Path string
+
+	// Text is set once the template is rendered.
+	Text     string
+	rendered bool
+}
+
+// Implementing the MarshalJSON method for InputTemplate
+func (it *InputTemplate) MarshalJSON() ([]byte, error) {
+	if !it.rendered {
+		return nil, fmt.Errorf("template %s is not rendered", it.Path)
+	}
+
+	return json.Marshal(map[string]string{
+		"type": "text",
+		"text": it.Text,
+	})
+}
+
+// Render renders the template. Partials like {{> header}} are read from files
+// relative to the template, and every variable the template or its partials
+// use outside of a section must be given.
+func (it *InputTemplate) Render(vars map[string]any) error {
+	partials := &templatePartials{dir: filepath.Dir(it.Path)}
+	tmpl, err := newTemplateCompiler(partials).CompileFile(it.Path)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", it.Path, err)
+	}
+
+	name, err := missingVariable(tmpl.Tags(), vars, partials, map[string]bool{})
+	if err != nil {
+		return fmt.Errorf("template %s: %w", it.Path, err)
+	}
+
+	if name != "" {
+		return fmt.Errorf("template %s: missing variable %q (set it with --var %s=...)", it.Path, name, name)
+	}
+
+	it.Text, err = tmpl.Render(vars)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", it.Path, err)
+	}
+	it.rendered = true
+
+	return nil
+}
+
+// newTemplateCompiler compiles templates that render variables as is, since
+// messages are not HTML.
+func newTemplateCompiler(partials *templatePartials) *mustache.Compiler {
+	return mustache.New().WithPartials(partials).WithEscapeMode(mustache.Raw)
+}
+
+// missingVariable returns the first variable used outside of a section that
+// vars doesn't set, or "" if there's none. The tags of partials are checked
+// too, since the template only has a tag for the partial itself.
+func missingVariable(tags []mustache.Tag, vars map[string]any, partials *templatePartials, seen map[string]bool) (string, error) {
+	for _, tag := range tags {
+		switch tag.Type() {
+		case mustache.Variable:
+			if tag.Name() == "." {
+				continue
+			}
+
+			name, _, _ := strings.Cut(tag.Name(), ".")
+			if _, ok := vars[name]; !ok {
+				return name, nil
+			}
+		case mustache.Partial:
+			// a partial may include itself
+			if seen[tag.Name()] {
+				continue
+			}
+			seen[tag.Name()] = true
+
+			data, err := partials.Get(tag.Name())
+			if err != nil {
+				return "", err
+			}
+
+			partial, err := newTemplateCompiler(partials).CompileString(data)
+			if err != nil {
+				return "", fmt.Errorf("partial %q: %w", tag.Name(), err)
+			}
+
+			name, err := missingVariable(partial.Tags(), vars, partials, seen)
+			if name != "" || err != nil {
+				return name, err
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// templatePartials reads partials from a directory. Unlike
+// mustache.FileProvider, a missing partial is an error rather than empty.
+type templatePartials struct {
+	dir string
+}
+
+func (p *templatePartials) Get(name string) (string, error) {
+	for _, ext := range []string{"", ".md", ".mustache"} {
+		data, err := os.ReadFile(filepath.Join(p.dir, name+ext))
+		if err == nil {
+			return string(data), nil
+		}
+	}
+
+	return "", fmt.Errorf("partial %q not found in %s", name, p.dir)
+}
+
+// ParseTemplateInput checks that a template file exists. It's rendered later
+// with renderTemplates, once the variables are known.
+func ParseTemplateInput(path string) (*InputTemplate, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("read template: %w", err)
+	}
+
+	return &InputTemplate{Path: path}, nil
+}
+
+// renderTemplates renders the template inputs.
+func renderTemplates(ms []json.Marshaler, vars map[string]any) error {
+	for _, m := range ms {
+		if it, ok := m.(*InputTemplate); ok {
+			err := it.Render(vars)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseTemplateVars reads the variables of a JSON file, if given, then sets
+// the key=value pairs over them.
+func ParseTemplateVars(file string, pairs []string) (map[string]any, error) {
+	vars := map[string]any{}
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read vars: %w", err)
+		}
+
+		err = json.Unmarshal(data, &vars)
+		if err != nil {
+			return nil, fmt.Errorf("read vars %s: %w", file, err)
+		}
+	}
+
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid var: %q (expected key=value)", pair)
+		}
+
+		vars[key] = value
+	}
+
+	return vars, nil
+}
@@ -2,52 +2,101 @@ package gpt
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/hayeah/goo/fetch"
+	"github.com/jmoiron/sqlx"
 	"github.com/sashabaranov/go-openai"
+	"github.com/tidwall/gjson"
 )
 
 type ThreadRunner struct {
-	AM *AssistantManager
+	AM      *AssistantManager
+	Threads *ThreadManager
+	Usage   *UsageManager
+	Budget  *BudgetManager
+	Files   *FileManager
 
 	oai   *OpenAIV2API
 	appDB *AppDB
+	cfg   *Config
 	log   *slog.Logger
 }
 
-func (tr *ThreadRunner) processInputs(inputs []string) ([]json.Marshaler, error) {
-	var ms []json.Marshaler
+func (tr *ThreadRunner) processInputs(parser *InputParser, inputs []string) ([]json.Marshaler, error) {
 	if len(inputs) == 0 {
 		inputs = append(inputs, "-")
 	}
 
-	for _, input := range inputs {
-		m, err := ParseInput(input)
-		if err != nil {
-			return nil, err
+	return parser.ParseAll(inputs)
+}
+
+// uploadInputs uploads the local files of inputs.
+func (tr *ThreadRunner) uploadInputs(ms []json.Marshaler) error {
+	for _, m := range ms {
+		switch m := m.(type) {
+		case *InputImageFile:
+			if m.FileID != "" {
+				continue
+			}
+
+			fileID, err := tr.Files.Upload(m.Name(), m.Data, "vision")
+			if err != nil {
+				return err
+			}
+
+			m.FileID = fileID
+		case *InputAttachment:
+			if m.FileID != "" {
+				continue
+			}
+
+			fileID, err := tr.Files.Upload(filepath.Base(m.Path), m.Data, "assistants")
+			if err != nil {
+				return err
+			}
+
+			m.FileID = fileID
 		}
+	}
 
-		ms = append(ms, m)
+	return nil
+}
+
+// checkBudget refuses to send over budget, unless forced.
+func (tr *ThreadRunner) checkBudget(force bool) error {
+	err := tr.Budget.Check()
+	if !errors.Is(err, ErrBudgetExceeded) {
+		return err
 	}
 
-	return ms, nil
+	if force {
+		tr.log.Warn("sending over budget", "err", err)
+		return nil
+	}
+
+	return fmt.Errorf("%w (use --force to send anyway)", err)
 }
 
 func (tr *ThreadRunner) RunStream(cmd SendCmdScope) error {
-	ai := tr.oai
-
-	ms, err := tr.processInputs(cmd.Inputs)
+	err := tr.checkBudget(cmd.Force)
 	if err != nil {
 		return err
 	}
 
-	assistantID, err := tr.AM.CurrentAssistantID()
-	if err != nil {
-		return err
+	assistantID := cmd.Assistant
+	if assistantID == "" {
+		assistantID, err = tr.AM.CurrentAssistantID()
+		if err != nil {
+			return err
+		}
 	}
 
 	var threadID string
@@ -59,50 +108,148 @@ func (tr *ThreadRunner) RunStream(cmd SendCmdScope) error {
 		}
 	}
 
+	vars, err := ParseTemplateVars(cmd.VarsFile, cmd.Vars)
+	if err != nil {
+		return err
+	}
+
+	var messages []Message
+	if cmd.Conversation != "" {
+		messages, err = LoadConversation(cmd.Conversation)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the inputs on the command line and in the composed message share stdin
+	parser := &InputParser{Stdin: os.Stdin, Literal: cmd.Literal}
+
+	// read stdin only if there's nothing else to send
+	var ms []json.Marshaler
+	if len(cmd.Inputs) > 0 || (!cmd.Edit && cmd.Conversation == "") {
+		ms, err = tr.processInputs(parser, cmd.Inputs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Edit {
+		composed, err := tr.Compose(assistantID, threadID, parser)
+		if err != nil {
+			return err
+		}
+
+		ms = append(ms, composed...)
+	}
+
+	err = renderTemplates(ms, vars)
+	if err != nil {
+		return err
+	}
+
+	opts, err := cmd.RunOptions()
+	if err != nil {
+		return err
+	}
+
+	inputMessages, err := NewMessages(ms)
+	if err != nil {
+		return err
+	}
+	messages = append(messages, inputMessages...)
+
+	var content []json.Marshaler
+	for _, m := range messages {
+		content = append(content, m.Content...)
+	}
+
+	// count with the default encoding if the assistant's model isn't known,
+	// rather than looking it up
+	err = tr.preflight(opts.Model, content, tr.tokenLimit(cmd.TokenLimit), cmd.Force)
+	if err != nil {
+		return err
+	}
+
+	// upload files only once the message is known to be sent
+	err = tr.uploadInputs(ms)
+	if err != nil {
+		return err
+	}
+
+	params := RunParams{
+		AssistantID: assistantID,
+		ThreadID:    threadID,
+		Messages:    messages,
+		Options:     opts,
+		Tools:       cmd.Tools,
+	}
+
+	if cmd.Schema != "" {
+		return tr.RunStructured(params, cmd.Schema, cmd.SchemaRetries)
+	}
+
+	out, flush := markdownWriter(cmd.Markdown)
+	defer flush()
+
+	_, err = tr.Run(params, out)
+	return err
+}
+
+// RunParams describes a run to start.
+type RunParams struct {
+	AssistantID string
+	// ThreadID is the thread to run. A new thread is created if empty.
+	ThreadID string
+	Messages []Message
+	Options  *RunOptions
+	// Tools is the command that executes function calls.
+	Tools string
+	// OnRunCreated, if set, is called once the stream reports the run's ID.
+	OnRunCreated func(threadID, runID string)
+}
+
+// Run starts a run and streams the assistant's reply to out. Returns the text
+// of the last message the assistant created.
+func (tr *ThreadRunner) Run(params RunParams, out io.Writer) (string, error) {
+	ai := tr.oai
+	threadID := params.ThreadID
+	opts := params.Options
+	if opts == nil {
+		opts = &RunOptions{}
+	}
+
+	var err error
+
+	// additional_instructions is not supported by create thread and run
+	if threadID == "" && opts.AdditionalInstructions != "" {
+		threadID, err = tr.createThread()
+		if err != nil {
+			return "", err
+		}
+	}
+
 	var sse *fetch.SSEResponse
 
 	if threadID == "" {
 		// https://platform.openai.com/docs/api-reference/runs/createThreadAndRun
-		// POST https://api.openai.com/v1/threads/{thread_id}/runs
+		// POST https://api.openai.com/v1/threads/runs
 		sse, err = ai.SSE("POST", "/threads/runs", &fetch.Options{
-			Body: `{
-				"assistant_id": {{assistantID}},
-				"thread": {
-				  "messages": [
-					{"role": "user", "content": [
-						{{#inputs}}
-						{{.}},
-						{{/inputs}}
-					]},
-				  ],
-				},
-				"stream": true,
-			}`,
-			BodyParams: map[string]any{
-				"assistantID": assistantID,
-				"inputs":      ms,
+			Body: &RunRequest{
+				AssistantID: params.AssistantID,
+				Thread:      &ThreadRequest{Messages: params.Messages},
+				Stream:      true,
+				RunOptions:  opts,
 			},
 		})
 	} else {
 		// https://platform.openai.com/docs/api-reference/runs/createRun
 		// POST https://api.openai.com/v1/threads/{thread_id}/runs
 		sse, err = ai.SSE("POST", "/threads/{{thread_id}}/runs", &fetch.Options{
-			Body: `{
-				"assistant_id": {{assistantID}},
-
-				"additional_messages": [
-					{"role": "user", "content": [
-						{{#inputs}}
-						{{.}},
-						{{/inputs}}
-					]},
-				],
-
-				"stream": true,
-			}`,
-			BodyParams: map[string]any{
-				"assistantID": assistantID,
-				"inputs":      ms,
+			Body: &RunRequest{
+				AssistantID:        params.AssistantID,
+				AdditionalMessages: params.Messages,
+				Stream:             true,
+				RunOptions:         opts,
 			},
 			PathParams: map[string]string{
 				"thread_id": threadID,
@@ -113,19 +260,24 @@ func (tr *ThreadRunner) RunStream(cmd SendCmdScope) error {
 	}
 
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer sse.Close()
+	// sse is replaced when tool outputs are submitted
+	defer func() { sse.Close() }()
 
 	f, err := os.Create("stream.sse")
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer f.Close()
 
 	log := tr.log
 	toolw := os.Stderr
 
+	var reply strings.Builder
+	var runID string
+	var runErr *RunError
+
 processStream:
 	sse.Tee(f)
 
@@ -137,18 +289,30 @@ processStream:
 			id := event.GJSON("id").String()
 			err = tr.appDB.PutCurrentThreadID(id)
 			if err != nil {
-				return err
+				return "", err
 			}
 		case "thread.run.created":
-			id := event.GJSON("id").String()
-			err = tr.appDB.PutCurrentRun(id)
+			runID = event.GJSON("id").String()
+			err = tr.appDB.PutCurrentRun(runID)
 			if err != nil {
-				return err
+				return "", err
 			}
+
+			err = tr.Usage.Record(RunRecordFromJSON(event.GJSON("@this")))
+			if err != nil {
+				return "", err
+			}
+
+			if params.OnRunCreated != nil {
+				params.OnRunCreated(event.GJSON("thread_id").String(), runID)
+			}
+		case "thread.message.created":
+			reply.Reset()
 		case "thread.message.delta":
 			result := event.GJSON("delta.content.#.text.value")
 			for _, item := range result.Array() {
-				fmt.Print(item.String())
+				fmt.Fprint(out, item.String())
+				reply.WriteString(item.String())
 			}
 		case "thread.run.step.delta":
 			result := event.GJSON(`delta.step_details.tool_calls.#(type==function)#.function`)
@@ -176,9 +340,9 @@ processStream:
 				args := item.Get("function.arguments").Str
 
 				log.Info("FunctionCall.Exec",
-					"name", name, "cmd", cmd.Tools, "args", args)
+					"name", name, "cmd", params.Tools, "args", args)
 
-				caller := CommandCaller{Program: cmd.Tools}
+				caller := CommandCaller{Program: params.Tools}
 
 				output, exitcode, err := caller.Exec(name, args)
 
@@ -231,26 +395,162 @@ processStream:
 			})
 
 			if err != nil {
-				return err
+				return "", err
 			}
 
 			goto processStream
 
+		case "thread.run.completed":
+			err = tr.Usage.Record(RunRecordFromJSON(event.GJSON("@this")))
+			if err != nil {
+				return "", err
+			}
+		case "thread.run.failed", "thread.run.incomplete", "thread.run.expired", "thread.run.cancelled":
+			// failed runs may still have used tokens
+			record := RunRecordFromJSON(event.GJSON("@this"))
+			err = tr.Usage.Record(record)
+			if err != nil {
+				return "", err
+			}
+
+			runErr = &RunError{
+				RunID:   record.RunID,
+				Status:  record.Status,
+				Code:    record.ErrorCode,
+				Message: record.ErrorMessage,
+			}
+		case "error":
+			e := event.GJSON("@this")
+			if e.Get("error").Exists() {
+				e = e.Get("error")
+			}
+
+			runErr = &RunError{
+				RunID:   runID,
+				Status:  "error",
+				Code:    e.Get("code").String(),
+				Message: e.Get("message").String(),
+			}
+
+			if runID != "" {
+				err = tr.Usage.RecordError(runID, runErr.Code, runErr.Message)
+				if err != nil {
+					return "", err
+				}
+			}
 		case "thread.run.step.completed":
-			fmt.Print("\n")
-			// NB: multipath doesn't work if there are spaces between the commas
-			// result := event.GJSON("{thread_id,id,usage}")
-			// fmt.Println(result)
+			fmt.Fprint(out, "\n")
 		case "done":
-			fmt.Print("\n")
+			fmt.Fprint(out, "\n")
 		}
 	}
 
-	return sse.Err()
+	err = sse.Err()
+	if err != nil {
+		return reply.String(), err
+	}
+
+	if runErr != nil {
+		return reply.String(), runErr
+	}
+
+	return reply.String(), nil
+}
+
+// createThread creates an empty thread, and makes it the current thread.
+func (tr *ThreadRunner) createThread() (string, error) {
+	// https://platform.openai.com/docs/api-reference/threads/createThread
+	// POST https://api.openai.com/v1/threads
+	r, err := tr.oai.JSON("POST", "/threads", &fetch.Options{
+		Body: map[string]any{},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	threadID := r.Get("id").String()
+	err = tr.appDB.PutCurrentThreadID(threadID)
+	if err != nil {
+		return "", err
+	}
+
+	return threadID, nil
 }
 
 type ThreadManager struct {
-	db *AppDB
+	db    *AppDB
+	oai   *OpenAIV2API
+	sqldb *sqlx.DB
+	files *FileManager
+}
+
+// ListMessages lists all messages of a thread, oldest first.
+func (tm *ThreadManager) ListMessages(threadID string) ([]gjson.Result, error) {
+	var messages []gjson.Result
+	var after string
+
+	for {
+		page, hasMore, err := tm.listMessagesPage(threadID, "asc", after)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, page...)
+
+		if !hasMore || len(page) == 0 {
+			return messages, nil
+		}
+
+		after = page[len(page)-1].Get("id").String()
+	}
+}
+
+func (tm *ThreadManager) listMessagesPage(threadID, order, after string) ([]gjson.Result, bool, error) {
+	query := "limit=100&order={{order}}"
+	if after != "" {
+		query += "&after={{after}}"
+	}
+
+	// https://platform.openai.com/docs/api-reference/messages/listMessages
+	// GET https://api.openai.com/v1/threads/{thread_id}/messages
+	r, err := tm.oai.JSON("GET", "/threads/{{thread_id}}/messages?"+query, &fetch.Options{
+		PathParams: map[string]string{
+			"thread_id": threadID,
+			"order":     order,
+			"after":     after,
+		},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return r.Get("data").Array(), r.Get("has_more").Bool(), nil
+}
+
+// MessageText joins the text content of a message.
+func MessageText(message gjson.Result) string {
+	var texts []string
+	for _, text := range message.Get("content.#(type==text)#.text.value").Array() {
+		texts = append(texts, text.String())
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+// LastReply returns the text of the latest assistant message in the thread.
+func (tm *ThreadManager) LastReply(threadID string) (string, error) {
+	messages, _, err := tm.listMessagesPage(threadID, "desc", "")
+	if err != nil {
+		return "", err
+	}
+
+	for _, message := range messages {
+		if message.Get("role").String() == "assistant" {
+			return MessageText(message), nil
+		}
+	}
+
+	return "", nil
 }
 
 // Use selects a thread
@@ -281,28 +581,21 @@ func (tm *ThreadManager) Show(threadID string) error {
 
 // Messages retrieves messages from the current thread
 func (tm *ThreadManager) Messages() error {
-	// threadID, err := tm.db.CurrentThreadID()
-	// if err != nil {
-	// 	return err
-	// }
-
-	// list, err := tm.OAI.ListMessage(context.Background(), threadID, nil, nil, nil, nil)
-	// if err != nil {
-	// 	return err
-	// }
+	threadID, err := tm.db.CurrentThreadID()
+	if err != nil {
+		return err
+	}
 
-	// // slices.Reverse()
-	// slices.Reverse(list.Messages)
+	messages, err := tm.ListMessages(threadID)
+	if err != nil {
+		return err
+	}
 
-	// for _, msg := range list.Messages {
-	// 	spew.Dump(msg.Role)
-	// 	for _, content := range msg.Content {
-	// 		if content.Text != nil {
-	// 			fmt.Print(content.Text.Value)
-	// 		}
-	// 	}
-	// 	fmt.Println()
-	// }
+	for _, msg := range messages {
+		fmt.Printf("# %s %s\n\n", msg.Get("id"), msg.Get("role"))
+		fmt.Println(MessageText(msg))
+		fmt.Println()
+	}
 
 	return nil
 }
@@ -0,0 +1,170 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	"github.com/tidwall/gjson"
+	"golang.org/x/term"
+)
+
+// defaultTokenLimit is the token limit of a message, if the config doesn't
+// set one. It's a little under the context window of gpt-4o.
+const defaultTokenLimit = 120_000
+
+// image token estimates. The real cost depends on the image size, which is
+// only known after the image is resized by the API.
+const (
+	lowDetailImageTokens  = 85
+	highDetailImageTokens = 765 // a 1024x1024 image
+)
+
+var useOfflineBPE sync.Once
+
+// TokenEncoding returns the tokenizer of a model. The BPE tables are embedded,
+// so this works offline. Models it doesn't know use o200k_base, which the
+// newer models share.
+func TokenEncoding(model string) (*tiktoken.Tiktoken, error) {
+	useOfflineBPE.Do(func() {
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+	})
+
+	enc, err := tiktoken.EncodingForModel(model)
+	if err == nil {
+		return enc, nil
+	}
+
+	return tiktoken.GetEncoding(tiktoken.MODEL_O200K_BASE)
+}
+
+// CountInputTokens estimates the prompt tokens of an input. Attachments count
+// as nothing, since what file_search retrieves isn't known in advance.
+func CountInputTokens(enc *tiktoken.Tiktoken, m json.Marshaler) int {
+	switch m := m.(type) {
+	case *InputText:
+		return len(enc.EncodeOrdinary(m.Text))
+	case *InputTemplate:
+		return len(enc.EncodeOrdinary(m.Text))
+	case *InputImageURL:
+		return imageTokens(m.Detail)
+	case *InputImageFile:
+		return imageTokens(m.Detail)
+	case json.RawMessage:
+		// a content part of a JSONL conversation
+		part := gjson.ParseBytes(m)
+		switch part.Get("type").String() {
+		case "text":
+			return len(enc.EncodeOrdinary(part.Get("text").String()))
+		case "image_url":
+			return imageTokens(part.Get("image_url.detail").String())
+		case "image_file":
+			return imageTokens(part.Get("image_file.detail").String())
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+func imageTokens(detail string) int {
+	if detail == "low" {
+		return lowDetailImageTokens
+	}
+
+	return highDetailImageTokens
+}
+
+// tokenLimit returns the limit on the tokens of a message.
+func (tr *ThreadRunner) tokenLimit(override int) int {
+	if override > 0 {
+		return override
+	}
+
+	if tr.cfg.TokenLimit > 0 {
+		return tr.cfg.TokenLimit
+	}
+
+	return defaultTokenLimit
+}
+
+// preflight estimates the prompt tokens of a message, and refuses to send it
+// over the token limit, unless forced. The thread's earlier messages are not
+// counted. An empty model counts with the default encoding.
+func (tr *ThreadRunner) preflight(model string, ms []json.Marshaler, limit int, force bool) error {
+	enc, err := TokenEncoding(model)
+	if err != nil {
+		return err
+	}
+
+	var total int
+	for _, m := range ms {
+		total += CountInputTokens(enc, m)
+	}
+
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		if model == "" {
+			model = "assistant's model"
+		}
+		fmt.Fprintf(os.Stderr, "~%d prompt tokens (%s)\n", total, model)
+	}
+
+	if total <= limit {
+		return nil
+	}
+
+	if force {
+		tr.log.Warn("sending over the token limit", "tokens", total, "limit", limit)
+		return nil
+	}
+
+	return fmt.Errorf("message is ~%d tokens, over the limit of %d (use --token-limit or --force to send anyway)", total, limit)
+}
+
+// Tokens prints the estimated tokens of inputs.
+func (tr *ThreadRunner) Tokens(cmd TokensCmd) error {
+	enc, err := TokenEncoding(cmd.Model)
+	if err != nil {
+		return err
+	}
+
+	vars, err := ParseTemplateVars(cmd.VarsFile, cmd.Vars)
+	if err != nil {
+		return err
+	}
+
+	inputs := cmd.Inputs
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	parser := &InputParser{Stdin: os.Stdin, Literal: cmd.Literal}
+
+	var total int
+	for _, input := range inputs {
+		m, err := parser.Parse(input)
+		if err != nil {
+			return err
+		}
+
+		err = renderTemplates([]json.Marshaler{m}, vars)
+		if err != nil {
+			return err
+		}
+
+		n := CountInputTokens(enc, m)
+		total += n
+
+		fmt.Fprintf(w, "%d\t %s\n", n, input)
+	}
+
+	fmt.Fprintf(w, "%d\t total (%s)\n", total, cmd.Model)
+
+	return w.Flush()
+}
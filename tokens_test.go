@@ -0,0 +1,58 @@
+package gpt
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountInputTokens(t *testing.T) {
+	assert := assert.New(t)
+
+	enc, err := TokenEncoding("gpt-4o")
+	assert.NoError(err)
+
+	assert.Equal(2, CountInputTokens(enc, &InputText{Text: "hello world"}))
+	assert.Equal(85, CountInputTokens(enc, &InputImageFile{Detail: "low"}))
+	assert.Equal(765, CountInputTokens(enc, &InputImageFile{}))
+	assert.Equal(0, CountInputTokens(enc, &InputAttachment{}))
+
+	// content parts of a JSONL conversation are counted like inputs
+	messages, err := LoadConversation("testdata/fewshot.jsonl")
+	assert.NoError(err)
+
+	var total int
+	for _, m := range messages {
+		for _, part := range m.Content {
+			total += CountInputTokens(enc, part)
+		}
+	}
+	var expected int
+	for _, text := range []string{"Translate: cat", "chat", "Translate: dog"} {
+		expected += CountInputTokens(enc, &InputText{Text: text})
+	}
+	assert.Equal(expected, total)
+	assert.NotZero(total)
+	assert.Equal(85, CountInputTokens(enc, json.RawMessage(`{"type": "image_url", "image_url": {"url": "https://example.com/a.png", "detail": "low"}}`)))
+
+	// unknown models fall back to o200k_base
+	_, err = TokenEncoding("gpt-next")
+	assert.NoError(err)
+}
+
+func TestPreflight(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := &ThreadRunner{log: slog.Default()}
+	ms := []json.Marshaler{&InputText{Text: "hello world"}, &InputImageFile{Detail: "low"}}
+
+	assert.NoError(tr.preflight("gpt-4o", ms, 87, false))
+	assert.ErrorContains(tr.preflight("gpt-4o", ms, 86, false), "over the limit of 86")
+	assert.NoError(tr.preflight("gpt-4o", ms, 86, true))
+
+	// without a model, the default encoding is used
+	assert.NoError(tr.preflight("", ms, 87, false))
+	assert.ErrorContains(tr.preflight("", ms, 86, false), "over the limit of 86")
+}
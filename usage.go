@@ -0,0 +1,310 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tidwall/gjson"
+)
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// defaultPrices are used for models not listed in the config's price table.
+var defaultPrices = map[string]ModelPrice{
+	"gpt-4o":        {Prompt: 5, Completion: 15},
+	"gpt-4o-mini":   {Prompt: 0.15, Completion: 0.6},
+	"gpt-4-turbo":   {Prompt: 10, Completion: 30},
+	"gpt-4":         {Prompt: 30, Completion: 60},
+	"gpt-3.5-turbo": {Prompt: 0.5, Completion: 1.5},
+}
+
+// PriceTable looks up model prices, with user configured prices taking
+// precedence over the defaults.
+type PriceTable map[string]ModelPrice
+
+// Lookup finds the price of a model. Dated snapshots (e.g. gpt-4o-2024-05-13)
+// fall back to the longest matching model name prefix.
+func (pt PriceTable) Lookup(model string) (ModelPrice, bool) {
+	prices := make(map[string]ModelPrice, len(defaultPrices)+len(pt))
+	for name, price := range defaultPrices {
+		prices[name] = price
+	}
+	for name, price := range pt {
+		prices[name] = price
+	}
+
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+
+	var best string
+	for name := range prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+
+	if best == "" {
+		return ModelPrice{}, false
+	}
+
+	return prices[best], true
+}
+
+// Cost returns the cost of a run in USD.
+func (pt PriceTable) Cost(model string, promptTokens, completionTokens int64) (float64, bool) {
+	price, ok := pt.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+
+	cost := float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion
+	return cost / 1e6, true
+}
+
+// RunRecord is a run recorded locally.
+type RunRecord struct {
+	RunID            string `db:"run_id"`
+	ThreadID         string `db:"thread_id"`
+	AssistantID      string `db:"assistant_id"`
+	Model            string `db:"model"`
+	Status           string `db:"status"`
+	PromptTokens     int64  `db:"prompt_tokens"`
+	CompletionTokens int64  `db:"completion_tokens"`
+	TotalTokens      int64  `db:"total_tokens"`
+
+	// ErrorCode is the last_error code of a failed run, or the reason an
+	// incomplete run stopped.
+	ErrorCode    string `db:"error_code"`
+	ErrorMessage string `db:"error_message"`
+}
+
+// RunRecordFromJSON extracts a run record from a run object.
+func RunRecordFromJSON(run gjson.Result) *RunRecord {
+	record := &RunRecord{
+		RunID:            run.Get("id").String(),
+		ThreadID:         run.Get("thread_id").String(),
+		AssistantID:      run.Get("assistant_id").String(),
+		Model:            run.Get("model").String(),
+		Status:           run.Get("status").String(),
+		PromptTokens:     run.Get("usage.prompt_tokens").Int(),
+		CompletionTokens: run.Get("usage.completion_tokens").Int(),
+		TotalTokens:      run.Get("usage.total_tokens").Int(),
+	}
+
+	switch {
+	case run.Get("last_error.code").Exists():
+		record.ErrorCode = run.Get("last_error.code").String()
+		record.ErrorMessage = run.Get("last_error.message").String()
+	case run.Get("incomplete_details.reason").Exists():
+		record.ErrorCode = run.Get("incomplete_details.reason").String()
+	}
+
+	return record
+}
+
+type UsageManager struct {
+	db  *sqlx.DB
+	cfg *Config
+}
+
+// Record upserts a run and its token usage.
+func (um *UsageManager) Record(run *RunRecord) error {
+	_, err := um.db.NamedExec(`
+		INSERT INTO runs (run_id, thread_id, assistant_id, model, status, prompt_tokens, completion_tokens, total_tokens, error_code, error_message)
+		VALUES (:run_id, :thread_id, :assistant_id, :model, :status, :prompt_tokens, :completion_tokens, :total_tokens, :error_code, :error_message)
+		ON CONFLICT(run_id) DO UPDATE SET
+			status = excluded.status,
+			prompt_tokens = excluded.prompt_tokens,
+			completion_tokens = excluded.completion_tokens,
+			total_tokens = excluded.total_tokens,
+			error_code = excluded.error_code,
+			error_message = excluded.error_message
+	`, run)
+	if err != nil {
+		return fmt.Errorf("record run: %w", err)
+	}
+
+	return nil
+}
+
+// RecordError marks a run as interrupted by a stream error.
+func (um *UsageManager) RecordError(runID string, code string, message string) error {
+	_, err := um.db.Exec(`
+		UPDATE runs SET status = 'error', error_code = ?, error_message = ?
+		WHERE run_id = ?
+	`, code, message, runID)
+	if err != nil {
+		return fmt.Errorf("record run error: %w", err)
+	}
+
+	return nil
+}
+
+// UsageRow is the aggregated usage of a group of runs.
+type UsageRow struct {
+	Key              string
+	Runs             int64
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+	Cost             float64
+}
+
+var usageGroupColumns = map[string]string{
+	"day":       "date(created_at)",
+	"assistant": "assistant_id",
+	"thread":    "thread_id",
+	"model":     "model",
+}
+
+// Usage aggregates token usage and cost of runs created since the given date
+// (e.g. "2024-05-01", or "" for all time), grouped by day, assistant, thread or
+// model.
+func (um *UsageManager) Usage(groupBy string, since string) ([]UsageRow, error) {
+	column, ok := usageGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("usage: cannot group by %q", groupBy)
+	}
+
+	// group by model as well, as the cost depends on the model
+	query := fmt.Sprintf(`
+		SELECT %s AS key, model, count(*) AS runs,
+			sum(prompt_tokens) AS prompt_tokens,
+			sum(completion_tokens) AS completion_tokens,
+			sum(total_tokens) AS total_tokens
+		FROM runs
+		WHERE created_at >= ?
+		GROUP BY key, model
+		ORDER BY key
+	`, column)
+
+	var rows []struct {
+		Key              string `db:"key"`
+		Model            string `db:"model"`
+		Runs             int64  `db:"runs"`
+		PromptTokens     int64  `db:"prompt_tokens"`
+		CompletionTokens int64  `db:"completion_tokens"`
+		TotalTokens      int64  `db:"total_tokens"`
+	}
+	err := um.db.Select(&rows, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("usage: %w", err)
+	}
+
+	prices := PriceTable(um.cfg.Prices)
+
+	var usage []UsageRow
+	index := map[string]int{}
+	for _, row := range rows {
+		i, ok := index[row.Key]
+		if !ok {
+			i = len(usage)
+			index[row.Key] = i
+			usage = append(usage, UsageRow{Key: row.Key})
+		}
+
+		u := &usage[i]
+		u.Runs += row.Runs
+		u.PromptTokens += row.PromptTokens
+		u.CompletionTokens += row.CompletionTokens
+		u.TotalTokens += row.TotalTokens
+
+		cost, _ := prices.Cost(row.Model, row.PromptTokens, row.CompletionTokens)
+		u.Cost += cost
+	}
+
+	return usage, nil
+}
+
+// Total sums the token usage and cost of runs created since the given date.
+func (um *UsageManager) Total(since string) (UsageRow, error) {
+	usage, err := um.Usage("model", since)
+	if err != nil {
+		return UsageRow{}, err
+	}
+
+	var total UsageRow
+	for _, u := range usage {
+		total.Runs += u.Runs
+		total.PromptTokens += u.PromptTokens
+		total.CompletionTokens += u.CompletionTokens
+		total.TotalTokens += u.TotalTokens
+		total.Cost += u.Cost
+	}
+
+	return total, nil
+}
+
+// Report prints the usage report.
+func (um *UsageManager) Report(cmd UsageCmd) error {
+	usage, err := um.Usage(cmd.By, cmd.Since)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\tRUNS\tPROMPT\tCOMPLETION\tTOTAL\tCOST (USD)\t\n", strings.ToUpper(cmd.By))
+
+	total := UsageRow{Key: "total"}
+	for _, u := range usage {
+		printUsageRow(w, u)
+
+		total.Runs += u.Runs
+		total.PromptTokens += u.PromptTokens
+		total.CompletionTokens += u.CompletionTokens
+		total.TotalTokens += u.TotalTokens
+		total.Cost += u.Cost
+	}
+	printUsageRow(w, total)
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	unpriced, err := um.unpricedModels()
+	if err != nil {
+		return err
+	}
+
+	if len(unpriced) > 0 {
+		fmt.Fprintf(os.Stderr, "no price configured for: %s\n", strings.Join(unpriced, ", "))
+	}
+
+	return nil
+}
+
+func printUsageRow(w io.Writer, u UsageRow) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.4f\t\n",
+		u.Key, u.Runs, u.PromptTokens, u.CompletionTokens, u.TotalTokens, u.Cost)
+}
+
+func (um *UsageManager) unpricedModels() ([]string, error) {
+	var models []string
+	err := um.db.Select(&models, "SELECT DISTINCT model FROM runs")
+	if err != nil {
+		return nil, err
+	}
+
+	prices := PriceTable(um.cfg.Prices)
+
+	var unpriced []string
+	for _, model := range models {
+		if _, ok := prices.Lookup(model); !ok {
+			unpriced = append(unpriced, model)
+		}
+	}
+	sort.Strings(unpriced)
+
+	return unpriced, nil
+}
@@ -0,0 +1,26 @@
+package gpt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceTable(t *testing.T) {
+	assert := assert.New(t)
+
+	prices := PriceTable{"gpt-4o": {Prompt: 1, Completion: 2}}
+
+	// configured prices take precedence over the defaults
+	cost, ok := prices.Cost("gpt-4o", 1_000_000, 1_000_000)
+	assert.True(ok)
+	assert.Equal(3.0, cost)
+
+	// dated snapshots match the longest model name prefix
+	price, ok := prices.Lookup("gpt-4o-mini-2024-07-18")
+	assert.True(ok)
+	assert.Equal(defaultPrices["gpt-4o-mini"], price)
+
+	_, ok = prices.Lookup("davinci")
+	assert.False(ok)
+}
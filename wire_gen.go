@@ -52,24 +52,57 @@ func InitApp() (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	openAIV2API := ProvideOAI(gptConfig)
+	openAIV2API := ProvideOAI(gptConfig, logger)
 	assistantManager := &AssistantManager{
 		oai:    openAIV2API,
 		JSONDB: jsondb,
+		db:     db,
 	}
 	appDB := ProvideAppDB(jsondb)
+	usageManager := &UsageManager{
+		db:  db,
+		cfg: gptConfig,
+	}
+	budgetManager := &BudgetManager{
+		Usage:  usageManager,
+		cfg:    gptConfig,
+		JSONDB: jsondb,
+	}
+	fileManager := &FileManager{
+		oai: openAIV2API,
+		db:  db,
+	}
 	threadManager := &ThreadManager{
-		db: appDB,
+		db:    appDB,
+		oai:   openAIV2API,
+		sqldb: db,
+		files: fileManager,
 	}
 	runManager := &RunManager{
 		ai: openAIV2API,
 		db: appDB,
 	}
 	threadRunner := &ThreadRunner{
+		AM:      assistantManager,
+		Threads: threadManager,
+		Usage:   usageManager,
+		Budget:  budgetManager,
+		Files:   fileManager,
+		oai:     openAIV2API,
+		appDB:   appDB,
+		cfg:     gptConfig,
+		log:     logger,
+	}
+	chat := &Chat{
+		TR:    threadRunner,
 		AM:    assistantManager,
-		oai:   openAIV2API,
+		RM:    runManager,
+		cfg:   gptConfig,
 		appDB: appDB,
-		log:   logger,
+	}
+	promptManager := &PromptManager{
+		TR: threadRunner,
+		db: db,
 	}
 	app := &App{
 		Args:             args,
@@ -78,6 +111,11 @@ func InitApp() (*App, error) {
 		ThreadManager:    threadManager,
 		RunManager:       runManager,
 		ThreadRunner:     threadRunner,
+		UsageManager:     usageManager,
+		BudgetManager:    budgetManager,
+		Chat:             chat,
+		FileManager:      fileManager,
+		PromptManager:    promptManager,
 	}
 	return app, nil
 }